@@ -0,0 +1,366 @@
+package circle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RelativePath returns the artifact's path relative to the artifacts
+// directory of the container that created it, for example
+// "coverage/coverage.out". The path is cleaned so it can't point outside of a
+// download directory.
+func (a *CircleArtifact) RelativePath() string {
+	p := a.PrettyPath
+	if p == "" {
+		p = a.Path
+	}
+	p = strings.TrimPrefix(p, "$CIRCLE_ARTIFACTS/")
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// LocalPath returns the slash separated path we save the artifact to, relative
+// to a download directory. Artifacts from each container are kept in their own
+// directory, named for the node index, so "0/coverage/coverage.out" and
+// "1/coverage/coverage.out" don't collide.
+func (a *CircleArtifact) LocalPath() string {
+	return path.Join(strconv.Itoa(int(a.NodeIndex)), a.RelativePath())
+}
+
+// FilterArtifacts returns the artifacts that match glob and ran on the given
+// node. If glob contains a slash, it is matched against the artifact's
+// RelativePath, otherwise against the file name. An empty glob matches every
+// artifact, and a negative node matches every node.
+func FilterArtifacts(arts []*CircleArtifact, glob string, node int) ([]*CircleArtifact, error) {
+	if glob != "" {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %v", glob, err)
+		}
+	}
+	filtered := make([]*CircleArtifact, 0, len(arts))
+	for _, art := range arts {
+		if node >= 0 && int(art.NodeIndex) != node {
+			continue
+		}
+		if glob != "" {
+			name := art.RelativePath()
+			if !strings.Contains(glob, "/") {
+				name = path.Base(name)
+			}
+			if ok, _ := path.Match(glob, name); !ok {
+				continue
+			}
+		}
+		filtered = append(filtered, art)
+	}
+	return filtered, nil
+}
+
+// ArtifactInfo describes an artifact's contents.
+type ArtifactInfo struct {
+	// Size is the size of the artifact in bytes, or -1 if the server didn't
+	// send one.
+	Size int64
+	// ModTime is the time the artifact was last modified, if known.
+	ModTime time.Time
+	// ContentType is the Content-Type the server sent for the artifact, if
+	// any.
+	ContentType string
+}
+
+// ArtifactBody is the contents of an artifact. Callers should close it when
+// they are done reading.
+type ArtifactBody struct {
+	io.ReadCloser
+	ArtifactInfo
+	// Offset is the position in the artifact the body starts at. It's only
+	// non-zero if the artifact was opened at an offset and the server
+	// supports range requests.
+	Offset int64
+}
+
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+func artifactURL(artifact *CircleArtifact, token string) (string, error) {
+	u, err := url.Parse(artifact.Url)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("circle-token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (o organization) artifactRequest(ctx context.Context, method string, artifact *CircleArtifact, offset int64) (*http.Response, error) {
+	client, err := o.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	u, err := artifactURL(artifact, o.Token)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("go-circle/%s", VERSION))
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	req = req.WithContext(ctx)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		return nil, errRangeNotSatisfiable
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading %s: request failed with status [%d]", artifact.RelativePath(), resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func artifactInfo(resp *http.Response) ArtifactInfo {
+	info := ArtifactInfo{Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+	return info
+}
+
+// parseContentRange returns the start and total length from a Content-Range
+// header like "bytes 100-199/200". total is -1 if the length is unknown.
+func parseContentRange(h string) (start int64, total int64, err error) {
+	if !strings.HasPrefix(h, "bytes ") {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	h = h[len("bytes "):]
+	slash := strings.IndexByte(h, '/')
+	dash := strings.IndexByte(h, '-')
+	if slash == -1 || dash == -1 || dash > slash {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	start, err = strconv.ParseInt(h[:dash], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	if h[slash+1:] == "*" {
+		return start, -1, nil
+	}
+	total, err = strconv.ParseInt(h[slash+1:], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", h)
+	}
+	return start, total, nil
+}
+
+func (o organization) openArtifact(ctx context.Context, artifact *CircleArtifact, offset int64) (*ArtifactBody, error) {
+	resp, err := o.artifactRequest(ctx, "GET", artifact, offset)
+	if err != nil {
+		return nil, err
+	}
+	body := &ArtifactBody{ReadCloser: resp.Body, ArtifactInfo: artifactInfo(resp)}
+	if resp.StatusCode == http.StatusPartialContent {
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		body.Offset = start
+		body.Size = total
+	}
+	return body, nil
+}
+
+// OpenArtifact starts downloading an artifact. If offset is greater than zero,
+// OpenArtifact asks the server to start at that byte; check the Offset of the
+// returned body to see whether it did.
+func OpenArtifact(ctx context.Context, artifact *CircleArtifact, org string, offset int64) (*ArtifactBody, error) {
+	o, err := getOrganization(org)
+	if err != nil {
+		return nil, err
+	}
+	return o.openArtifact(ctx, artifact, offset)
+}
+
+// StatArtifact returns the size and modification time of an artifact without
+// downloading it.
+func StatArtifact(ctx context.Context, artifact *CircleArtifact, org string) (*ArtifactInfo, error) {
+	o, err := getOrganization(org)
+	if err != nil {
+		return nil, err
+	}
+	return o.statArtifact(ctx, artifact)
+}
+
+func (o organization) statArtifact(ctx context.Context, artifact *CircleArtifact) (*ArtifactInfo, error) {
+	resp, err := o.artifactRequest(ctx, "HEAD", artifact, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	info := artifactInfo(resp)
+	return &info, nil
+}
+
+// A Reporter receives progress updates while an artifact downloads. name is
+// the artifact's LocalPath, n is the number of bytes written to the
+// destination so far (including any bytes from an earlier, resumed attempt)
+// and total is the size of the artifact, or -1 if it's unknown. Report is
+// called once when the download starts and again as data arrives. A Reporter
+// may be shared between concurrent downloads, so Report must be safe to call
+// from multiple goroutines.
+type Reporter interface {
+	Report(name string, n, total int64)
+}
+
+// ReporterFunc adapts an ordinary function to the Reporter interface.
+type ReporterFunc func(name string, n, total int64)
+
+// Report calls f(name, n, total).
+func (f ReporterFunc) Report(name string, n, total int64) {
+	f(name, n, total)
+}
+
+type DownloadOptions struct {
+	// SkipExisting skips the download if the destination file already exists
+	// and is the same size as the artifact.
+	SkipExisting bool
+	// Reporter, if set, is notified as the download progresses. Skipped
+	// downloads are reported once, as complete.
+	Reporter Reporter
+}
+
+// progressWriter reports the number of bytes written through it.
+type progressWriter struct {
+	w        io.Writer
+	name     string
+	n, total int64
+	r        Reporter
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+	p.r.Report(p.name, p.n, p.total)
+	return n, err
+}
+
+type DownloadResult struct {
+	// Path is the file the artifact was written to.
+	Path string
+	// Bytes is the number of bytes downloaded. If the download was resumed,
+	// this is less than the size of the file.
+	Bytes   int64
+	Skipped bool
+	Resumed bool
+}
+
+// DownloadArtifact downloads artifact to its LocalPath in directory.
+func DownloadArtifact(ctx context.Context, artifact *CircleArtifact, directory string, org string) error {
+	_, err := DownloadArtifactWithOptions(ctx, artifact, directory, org, nil)
+	return err
+}
+
+// DownloadArtifactWithOptions downloads artifact to its LocalPath in
+// directory. The artifact is written to a file ending in ".part" and renamed
+// once it is complete, so a file at the final path is never partially
+// written. If a ".part" file is left over from an earlier attempt, the download
+// resumes where it stopped.
+func DownloadArtifactWithOptions(ctx context.Context, artifact *CircleArtifact, directory string, org string, opts *DownloadOptions) (*DownloadResult, error) {
+	o, err := getOrganization(org)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil {
+		opts = new(DownloadOptions)
+	}
+	return o.downloadArtifact(ctx, artifact, directory, opts)
+}
+
+func (o organization) downloadArtifact(ctx context.Context, artifact *CircleArtifact, directory string, opts *DownloadOptions) (*DownloadResult, error) {
+	dest := filepath.Join(directory, filepath.FromSlash(artifact.LocalPath()))
+	res := &DownloadResult{Path: dest}
+	if opts.SkipExisting {
+		if fi, err := os.Stat(dest); err == nil {
+			info, err := o.statArtifact(ctx, artifact)
+			if err == nil && info.Size == fi.Size() {
+				res.Skipped = true
+				if opts.Reporter != nil {
+					opts.Reporter.Report(artifact.LocalPath(), info.Size, info.Size)
+				}
+				return res, nil
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	part := dest + ".part"
+	var offset int64
+	if fi, err := os.Stat(part); err == nil {
+		offset = fi.Size()
+	}
+	body, err := o.openArtifact(ctx, artifact, offset)
+	if err == errRangeNotSatisfiable {
+		// the partial file is no good, start over.
+		offset = 0
+		body, err = o.openArtifact(ctx, artifact, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset > 0 && body.Offset == offset {
+		flags |= os.O_APPEND
+		res.Resumed = true
+	} else {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer = f
+	if opts.Reporter != nil {
+		pw := &progressWriter{w: f, name: artifact.LocalPath(), n: body.Offset, total: body.Size, r: opts.Reporter}
+		opts.Reporter.Report(pw.name, pw.n, pw.total)
+		w = pw
+	}
+	n, err := io.Copy(w, body)
+	res.Bytes = n
+	if err != nil {
+		f.Close()
+		return res, err
+	}
+	if err := f.Close(); err != nil {
+		return res, err
+	}
+	if body.Size >= 0 && body.Offset+n != body.Size {
+		return res, fmt.Errorf("downloading %s: got %d bytes, expected %d", artifact.RelativePath(), body.Offset+n, body.Size)
+	}
+	if !body.ModTime.IsZero() {
+		if err := os.Chtimes(part, body.ModTime, body.ModTime); err != nil {
+			return res, err
+		}
+	}
+	if err := os.Rename(part, dest); err != nil {
+		return res, err
+	}
+	return res, nil
+}
@@ -0,0 +1,187 @@
+package circle
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		art  CircleArtifact
+		want string
+	}{
+		{CircleArtifact{PrettyPath: "$CIRCLE_ARTIFACTS/coverage.out", NodeIndex: 1}, "1/coverage.out"},
+		{CircleArtifact{PrettyPath: "tmp/reports/junit.xml"}, "0/tmp/reports/junit.xml"},
+		{CircleArtifact{PrettyPath: "/../../etc/passwd", NodeIndex: 2}, "2/etc/passwd"},
+		{CircleArtifact{Path: "reports/a/../b.txt", NodeIndex: 3}, "3/reports/b.txt"},
+	}
+	for _, tt := range tests {
+		if got := tt.art.LocalPath(); got != tt.want {
+			t.Errorf("LocalPath(%q): got %q, want %q", tt.art.PrettyPath+tt.art.Path, got, tt.want)
+		}
+	}
+}
+
+func TestFilterArtifacts(t *testing.T) {
+	arts := []*CircleArtifact{
+		{PrettyPath: "coverage/coverage.out", NodeIndex: 0},
+		{PrettyPath: "coverage/coverage.out", NodeIndex: 1},
+		{PrettyPath: "reports/junit.xml", NodeIndex: 1},
+	}
+	tests := []struct {
+		glob string
+		node int
+		want int
+	}{
+		{"", -1, 3},
+		{"*.out", -1, 2},
+		{"*.out", 1, 1},
+		{"reports/*", -1, 1},
+		{"*", 0, 1},
+	}
+	for _, tt := range tests {
+		got, err := FilterArtifacts(arts, tt.glob, tt.node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("FilterArtifacts(%q, %d): got %d artifacts, want %d", tt.glob, tt.node, len(got), tt.want)
+		}
+	}
+	if _, err := FilterArtifacts(arts, "[", -1); err == nil {
+		t.Errorf("expected invalid glob to return an error")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	start, total, err := parseContentRange("bytes 100-199/200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != 100 || total != 200 {
+		t.Errorf("got start %d total %d, want 100 and 200", start, total)
+	}
+	if _, total, _ := parseContentRange("bytes 5-9/*"); total != -1 {
+		t.Errorf("expected unknown total to be -1, got %d", total)
+	}
+	if _, _, err := parseContentRange("items 1-2/3"); err == nil {
+		t.Errorf("expected error for non-byte range")
+	}
+}
+
+var artifactContent = []byte(strings.Repeat("mode: set\ngithub.com/kevinburke/go-circle/circle.go:1.1,2.2 1 1\n", 50))
+var artifactModTime = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newArtifactServer(t *testing.T, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("circle-token") != "tok" {
+			t.Errorf("expected circle-token to be set, got %q", r.URL.RawQuery)
+		}
+		*requests = append(*requests, r.Method+" "+r.Header.Get("Range"))
+		http.ServeContent(w, r, "coverage.out", artifactModTime, bytes.NewReader(artifactContent))
+	}))
+}
+
+func TestDownloadArtifactResume(t *testing.T) {
+	var requests []string
+	s := newArtifactServer(t, &requests)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "go-circle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	art := &CircleArtifact{PrettyPath: "coverage/coverage.out", NodeIndex: 1, Url: s.URL + "/1/coverage/coverage.out"}
+	dest := filepath.Join(dir, "1", "coverage", "coverage.out")
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dest+".part", artifactContent[:100], 0644); err != nil {
+		t.Fatal(err)
+	}
+	o := organization{Token: "tok"}
+	res, err := o.downloadArtifact(context.Background(), art, dir, &DownloadOptions{SkipExisting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Resumed {
+		t.Errorf("expected download to resume")
+	}
+	if res.Bytes != int64(len(artifactContent)-100) {
+		t.Errorf("expected to download %d bytes, got %d", len(artifactContent)-100, res.Bytes)
+	}
+	data, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, artifactContent) {
+		t.Errorf("downloaded content does not match")
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Errorf("expected .part file to be renamed, got %v", err)
+	}
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(artifactModTime) {
+		t.Errorf("expected mtime %v, got %v", artifactModTime, fi.ModTime())
+	}
+
+	requests = nil
+	res, err = o.downloadArtifact(context.Background(), art, dir, &DownloadOptions{SkipExisting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Skipped {
+		t.Errorf("expected second download to be skipped")
+	}
+	if len(requests) != 1 || requests[0] != "HEAD " {
+		t.Errorf("expected a single HEAD request, got %v", requests)
+	}
+}
+
+func TestDownloadArtifactReporter(t *testing.T) {
+	var requests []string
+	s := newArtifactServer(t, &requests)
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "go-circle-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	art := &CircleArtifact{PrettyPath: "coverage.out", NodeIndex: 2, Url: s.URL + "/2/coverage.out"}
+	var reports []int64
+	r := ReporterFunc(func(name string, n, total int64) {
+		if name != "2/coverage.out" {
+			t.Errorf("expected name 2/coverage.out, got %q", name)
+		}
+		if total != int64(len(artifactContent)) {
+			t.Errorf("expected total %d, got %d", len(artifactContent), total)
+		}
+		reports = append(reports, n)
+	})
+	o := organization{Token: "tok"}
+	opts := &DownloadOptions{SkipExisting: true, Reporter: r}
+	if _, err := o.downloadArtifact(context.Background(), art, dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) < 2 || reports[0] != 0 || reports[len(reports)-1] != int64(len(artifactContent)) {
+		t.Errorf("expected reports from 0 to %d, got %v", len(artifactContent), reports)
+	}
+	reports = nil
+	if _, err := o.downloadArtifact(context.Background(), art, dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0] != int64(len(artifactContent)) {
+		t.Errorf("expected skipped download to report once as complete, got %v", reports)
+	}
+}
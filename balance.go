@@ -0,0 +1,146 @@
+package circle
+
+import (
+	"sort"
+	"time"
+)
+
+// NodeUsage is the time one container in a build spent running steps.
+type NodeUsage struct {
+	Node int
+	// Busy is the total run time of the container's actions.
+	Busy time.Duration
+	// Idle is how much less time the container was busy than the slowest
+	// container, which the build waited on.
+	Idle time.Duration
+}
+
+// Balance describes how evenly a build's work was spread over its
+// containers.
+type Balance struct {
+	Nodes []NodeUsage
+	// WallClock is the busy time of the slowest container.
+	WallClock time.Duration
+	// Busy is the total busy time of every container.
+	Busy time.Duration
+	// Wasted is the total idle time of every container.
+	Wasted time.Duration
+}
+
+// Efficiency returns the fraction of the containers' time that was spent
+// running steps, between 0 and 1.
+func (b *Balance) Efficiency() float64 {
+	if b.Busy+b.Wasted == 0 {
+		return 1
+	}
+	return float64(b.Busy) / float64(b.Busy+b.Wasted)
+}
+
+// Balance computes the busy and idle time of each container in the build from
+// the run times of its actions.
+func (cb *CircleBuild) Balance() *Balance {
+	n := int(cb.Parallel)
+	for _, step := range cb.Steps {
+		for _, action := range step.Actions {
+			if int(action.Index) >= n {
+				n = int(action.Index) + 1
+			}
+		}
+	}
+	b := &Balance{Nodes: make([]NodeUsage, n)}
+	for i := range b.Nodes {
+		b.Nodes[i].Node = i
+	}
+	for _, step := range cb.Steps {
+		for _, action := range step.Actions {
+			if action.Runtime < 0 {
+				continue
+			}
+			b.Nodes[action.Index].Busy += time.Duration(action.Runtime)
+		}
+	}
+	for _, node := range b.Nodes {
+		b.Busy += node.Busy
+		if node.Busy > b.WallClock {
+			b.WallClock = node.Busy
+		}
+	}
+	for i := range b.Nodes {
+		b.Nodes[i].Idle = b.WallClock - b.Nodes[i].Busy
+		b.Wasted += b.Nodes[i].Idle
+	}
+	return b
+}
+
+// SplitItem is a unit of work that can be assigned to a container, like a
+// test file.
+type SplitItem struct {
+	Name string
+	Time time.Duration
+}
+
+// NodeAssignment is the work assigned to one container.
+type NodeAssignment struct {
+	Node  int
+	Items []SplitItem
+	Total time.Duration
+}
+
+// TestSplitItems groups test results into the units "circleci tests split"
+// assigns to containers: test files, or the test's class name if the file
+// isn't known. Items are sorted by time, longest first.
+func TestSplitItems(results []*TestResult) []SplitItem {
+	times := make(map[string]time.Duration)
+	for _, t := range results {
+		name := t.File
+		if name == "" {
+			name = t.Classname
+		}
+		if name == "" {
+			continue
+		}
+		times[name] += t.Duration()
+	}
+	items := make([]SplitItem, 0, len(times))
+	for name, d := range times {
+		items = append(items, SplitItem{Name: name, Time: d})
+	}
+	sortSplitItems(items)
+	return items
+}
+
+func sortSplitItems(items []SplitItem) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Time != items[j].Time {
+			return items[i].Time > items[j].Time
+		}
+		return items[i].Name < items[j].Name
+	})
+}
+
+// Rebalance assigns items to n containers, so each container does about the
+// same amount of work. Items are assigned longest first, each to the
+// container with the least work so far.
+func Rebalance(items []SplitItem, n int) []NodeAssignment {
+	if n < 1 {
+		n = 1
+	}
+	sorted := make([]SplitItem, len(items))
+	copy(sorted, items)
+	sortSplitItems(sorted)
+	nodes := make([]NodeAssignment, n)
+	for i := range nodes {
+		nodes[i].Node = i
+	}
+	for _, item := range sorted {
+		least := 0
+		for i := range nodes {
+			if nodes[i].Total < nodes[least].Total {
+				least = i
+			}
+		}
+		nodes[least].Items = append(nodes[least].Items, item)
+		nodes[least].Total += item.Time
+	}
+	return nodes
+}
@@ -0,0 +1,61 @@
+package circle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBalance(t *testing.T) {
+	cb := &CircleBuild{
+		Parallel: 3,
+		Steps: []Step{
+			{Name: "checkout", Actions: []Action{
+				{Index: 0, Runtime: CircleDuration(time.Minute)},
+				{Index: 1, Runtime: CircleDuration(time.Minute)},
+				{Index: 2, Runtime: CircleDuration(time.Minute)},
+			}},
+			{Name: "go test", Actions: []Action{
+				{Index: 0, Runtime: CircleDuration(5 * time.Minute)},
+				{Index: 1, Runtime: CircleDuration(2 * time.Minute)},
+				{Index: 2, Runtime: -1},
+			}},
+		},
+	}
+	b := cb.Balance()
+	if len(b.Nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(b.Nodes))
+	}
+	if b.WallClock != 6*time.Minute {
+		t.Errorf("expected wall clock 6m, got %v", b.WallClock)
+	}
+	if b.Nodes[1].Idle != 3*time.Minute || b.Nodes[2].Idle != 5*time.Minute {
+		t.Errorf("unexpected idle times: %+v", b.Nodes)
+	}
+	if b.Wasted != 8*time.Minute || b.Busy != 10*time.Minute {
+		t.Errorf("expected 8m wasted and 10m busy, got %v and %v", b.Wasted, b.Busy)
+	}
+	if e := b.Efficiency(); e < 0.55 || e > 0.56 {
+		t.Errorf("expected efficiency 10/18, got %v", e)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	items := TestSplitItems([]*TestResult{
+		{File: "a_test.go", RunTime: 4},
+		{File: "a_test.go", RunTime: 1},
+		{File: "b_test.go", RunTime: 4},
+		{File: "c_test.go", RunTime: 3},
+		{Classname: "example.com/d", RunTime: 3},
+		{File: "e_test.go", RunTime: 2},
+	})
+	if len(items) != 5 || items[0].Name != "a_test.go" || items[0].Time != 5*time.Second {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+	nodes := Rebalance(items, 2)
+	if nodes[0].Total != 8*time.Second || nodes[1].Total != 9*time.Second {
+		t.Errorf("unexpected assignment: %+v", nodes)
+	}
+	if len(Rebalance(items, 0)) != 1 {
+		t.Errorf("expected Rebalance to use at least one node")
+	}
+}
@@ -0,0 +1,87 @@
+package circle
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// BuildCache stores finished builds on disk, so reports over many builds
+// don't need to fetch them again. Builds that are still running are never
+// cached. The presigned output URLs in cached builds may have expired.
+type BuildCache struct {
+	// Dir is the directory builds are stored in.
+	Dir string
+
+	// fetch gets a build that isn't in the cache. Tests replace it.
+	fetch func(ctx context.Context, host, org, project string, buildNum int) (*CircleBuild, error)
+}
+
+// DefaultBuildCache returns a cache in $XDG_CACHE_HOME/go-circle/builds, or
+// ~/.cache/go-circle/builds if XDG_CACHE_HOME isn't set.
+func DefaultBuildCache() *BuildCache {
+	dir, ok := os.LookupEnv("XDG_CACHE_HOME")
+	if !ok || dir == "" {
+		dir = filepath.Join(homeDir(), ".cache")
+	}
+	return &BuildCache{Dir: filepath.Join(dir, "go-circle", "builds")}
+}
+
+func (c *BuildCache) path(host, org, project string, buildNum int) string {
+	return filepath.Join(c.Dir, host, org, project, strconv.Itoa(buildNum)+".json")
+}
+
+// GetBuild returns a build from the cache. If it isn't there, GetBuild fetches
+// it from CircleCI, and caches it if it has finished. Errors writing to the
+// cache are ignored.
+func (c *BuildCache) GetBuild(ctx context.Context, host, org, project string, buildNum int) (*CircleBuild, error) {
+	filename := c.path(host, org, project, buildNum)
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		cb := new(CircleBuild)
+		if err := json.Unmarshal(data, cb); err == nil {
+			return cb, nil
+		}
+		// a corrupt entry gets overwritten below.
+	}
+	fetch := c.fetch
+	if fetch == nil {
+		fetch = GetBuild
+	}
+	cb, err := fetch(ctx, host, org, project, buildNum)
+	if err != nil {
+		return nil, err
+	}
+	if cb.Finished() {
+		c.put(filename, cb)
+	}
+	return cb, nil
+}
+
+func (c *BuildCache) put(filename string, cb *CircleBuild) error {
+	data, err := json.Marshal(cb)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file and rename it, so a concurrent reader never
+	// sees a partial build.
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".build")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
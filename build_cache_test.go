@@ -0,0 +1,49 @@
+package circle
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBuildCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-circle-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fetches := 0
+	c := &BuildCache{Dir: dir, fetch: func(ctx context.Context, host, org, project string, buildNum int) (*CircleBuild, error) {
+		fetches++
+		status := "success"
+		if buildNum == 2 {
+			status = "running"
+		}
+		return &CircleBuild{BuildNum: uint32(buildNum), Status: status, Steps: []Step{
+			{Name: "make test", Actions: []Action{{Runtime: CircleDuration(3 * time.Second)}}},
+		}}, nil
+	}}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		cb, err := c.GetBuild(ctx, "github.com", "kevinburke", "go-circle", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cb.Steps[0].Actions[0].Runtime != CircleDuration(3*time.Second) {
+			t.Errorf("run time didn't survive the cache: %v", cb.Steps[0].Actions[0].Runtime)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected finished build to be fetched once, got %d fetches", fetches)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetBuild(ctx, "github.com", "kevinburke", "go-circle", 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 3 {
+		t.Errorf("expected running build to be fetched every time, got %d fetches", fetches)
+	}
+}
@@ -41,17 +41,118 @@ func timeScaler(d time.Duration) string {
 	}
 }
 
+// StatisticsOptions configures the output of StatisticsWithOptions.
+type StatisticsOptions struct {
+	// TTY surrounds failed actions and slower steps with red ANSI escape
+	// sequences.
+	TTY bool
+	// Previous, if set, is a build to compare against, usually the previous
+	// successful build. Each step gets a column with the change in its run
+	// time.
+	Previous *CircleBuild
+	// Threshold is how much slower than in Previous a step has to be before
+	// it is highlighted. Without a TTY, highlighted deltas end with a "!".
+	Threshold time.Duration
+}
+
 // Statistics prints out statistics for the given build. If stdout is a TTY,
 // failed builds will be surrounded by red ANSI escape sequences.
 func (cb *CircleBuild) Statistics(tty bool) string {
+	return cb.StatisticsWithOptions(&StatisticsOptions{TTY: tty})
+}
+
+// stepRuntime returns the longest run time of any of the step's actions, or
+// -1 if none of them have finished.
+func stepRuntime(step Step) time.Duration {
+	d := time.Duration(-1)
+	for _, action := range step.Actions {
+		if action.Runtime >= 0 && time.Duration(action.Runtime) > d {
+			d = time.Duration(action.Runtime)
+		}
+	}
+	return d
+}
+
+// stepKey identifies a step across builds. Builds can run several steps with
+// the same name, so the key includes the number of earlier steps with that
+// name.
+type stepKey struct {
+	name string
+	n    int
+}
+
+func stepRuntimes(cb *CircleBuild) map[stepKey]time.Duration {
+	seen := make(map[string]int)
+	runtimes := make(map[stepKey]time.Duration, len(cb.Steps))
+	for _, step := range cb.Steps {
+		runtimes[stepKey{step.Name, seen[step.Name]}] = stepRuntime(step)
+		seen[step.Name]++
+	}
+	return runtimes
+}
+
+// FormatRuntime formats a step's run time the way Statistics does, like "4.2s"
+// or "1m05s". Run times over a minute are rounded to the second, and shorter
+// ones to 10ms. A run time of -1, for a step that hasn't finished, is
+// formatted as an empty string.
+func FormatRuntime(d time.Duration) string {
+	switch {
+	case d == -1:
+	case d > time.Minute:
+		d = d.Round(time.Second)
+	default:
+		d = d.Round(time.Millisecond * 10)
+	}
+	return timeScaler(d)
+}
+
+// FormatDelta formats the change in a step's run time the way
+// StatisticsWithOptions does, like "+12.3s".
+func FormatDelta(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	if d > time.Minute {
+		d = d.Round(time.Second)
+	} else {
+		d = d.Round(time.Millisecond * 100)
+	}
+	if d == 0 {
+		return "0.0s"
+	}
+	return sign + timeScaler(d)
+}
+
+// StatisticsWithOptions is like Statistics, but can also compare the build
+// with an earlier build.
+func (cb *CircleBuild) StatisticsWithOptions(opts *StatisticsOptions) string {
+	if opts == nil {
+		opts = new(StatisticsOptions)
+	}
+	tty := opts.TTY
+	var prev map[stepKey]time.Duration
+	if opts.Previous != nil {
+		prev = stepRuntimes(opts.Previous)
+	}
 	var b strings.Builder
+	if opts.Previous != nil {
+		fmt.Fprintf(&b, "Compared with build %d\n\n", opts.Previous.BuildNum)
+	}
 	b.WriteString(fmt.Sprintf(stepPadding, "Step"))
 	l := stepWidth
 	for i := uint8(0); i < cb.Parallel; i++ {
 		b.WriteString(fmt.Sprintf("%8d", i))
 		l += 8
 	}
+	if prev != nil {
+		b.WriteString(fmt.Sprintf("%10s", "Delta"))
+		l += 10
+	}
 	b.WriteString(fmt.Sprintf("\n%s\n", strings.Repeat("=", l)))
+	seen := make(map[string]int)
+	var total, prevTotal time.Duration
 	for _, step := range cb.Steps {
 		stepName := strings.Replace(step.Name, "\n", "\\n", -1)
 		if len(stepName) > stepWidth-2 {
@@ -66,27 +167,160 @@ func (cb *CircleBuild) Statistics(tty bool) string {
 				b.WriteString("        ")
 				i++
 			}
-			var dur time.Duration
-			switch {
-			case action.Runtime == -1:
-				dur = -1
-			case time.Duration(action.Runtime) > time.Minute:
-				dur = time.Duration(action.Runtime).Round(time.Second)
-			default:
-				dur = time.Duration(action.Runtime).Round(time.Millisecond * 10)
-			}
+			runtime := FormatRuntime(time.Duration(action.Runtime))
 			if action.Failed() && tty {
 				// color the output red
-				fmt.Fprintf(&b, "\033[38;05;160m%8s\033[0m", timeScaler(dur))
+				fmt.Fprintf(&b, "\033[38;05;160m%8s\033[0m", runtime)
 			} else {
-				fmt.Fprintf(&b, "%8s", timeScaler(dur))
+				fmt.Fprintf(&b, "%8s", runtime)
 			}
 			i++
 		}
+		if prev != nil {
+			key := stepKey{step.Name, seen[step.Name]}
+			seen[step.Name]++
+			for ; i < uint16(cb.Parallel); i++ {
+				b.WriteString("        ")
+			}
+			cur := stepRuntime(step)
+			before, ok := prev[key]
+			switch {
+			case !ok:
+				fmt.Fprintf(&b, "%10s", "new")
+			case cur < 0 || before < 0:
+				fmt.Fprintf(&b, "%10s", "")
+			default:
+				total += cur
+				prevTotal += before
+				b.WriteString(highlightDelta(cur-before, opts.Threshold, tty))
+			}
+		}
 		b.WriteString("\n")
 	}
+	if prev != nil && cb.Finished() {
+		fmt.Fprintf(&b, "\n%s%s\n", fmt.Sprintf(stepPadding, "Total of compared steps"),
+			strings.TrimLeft(highlightDelta(total-prevTotal, opts.Threshold, tty), " "))
+	}
 	if cb.Status == "running" {
 		fmt.Fprintf(&b, "\nBuild %d running... %s elapsed\n", cb.BuildNum, cb.Elapsed().Round(time.Second))
 	}
 	return b.String()
 }
+
+// highlightDelta formats a delta column for d, highlighting it if the step got
+// more than threshold slower.
+func highlightDelta(d, threshold time.Duration, tty bool) string {
+	delta := FormatDelta(d)
+	if threshold <= 0 || d <= threshold {
+		return fmt.Sprintf("%10s", delta)
+	}
+	if tty {
+		return fmt.Sprintf("\033[38;05;160m%10s\033[0m", delta)
+	}
+	return fmt.Sprintf("%10s", delta+"!")
+}
+
+// StepComparison is the run time of a step on one node in two builds.
+type StepComparison struct {
+	Step string
+	Node int
+	// InA and InB report whether the step ran on the node in each build, and
+	// A and B are its run times. A run time is -1 if the step hasn't
+	// finished.
+	InA, InB bool
+	A, B     time.Duration
+}
+
+// Added reports whether the step only ran in the second build.
+func (s StepComparison) Added() bool {
+	return !s.InA && s.InB
+}
+
+// Removed reports whether the step only ran in the first build.
+func (s StepComparison) Removed() bool {
+	return s.InA && !s.InB
+}
+
+// Comparable reports whether the step finished in both builds.
+func (s StepComparison) Comparable() bool {
+	return s.InA && s.InB && s.A >= 0 && s.B >= 0
+}
+
+// Delta returns how much longer the step took in the second build.
+func (s StepComparison) Delta() time.Duration {
+	return s.B - s.A
+}
+
+// Percent returns the change in run time as a percentage of the run time in
+// the first build, or 0 if the step took no time in the first build.
+func (s StepComparison) Percent() float64 {
+	if s.A <= 0 {
+		return 0
+	}
+	return 100 * float64(s.Delta()) / float64(s.A)
+}
+
+type nodeStepKey struct {
+	step stepKey
+	node int
+}
+
+func nodeStepRuntimes(cb *CircleBuild) ([]nodeStepKey, map[nodeStepKey]time.Duration) {
+	seen := make(map[string]int)
+	var keys []nodeStepKey
+	runtimes := make(map[nodeStepKey]time.Duration)
+	for _, step := range cb.Steps {
+		sk := stepKey{step.Name, seen[step.Name]}
+		seen[step.Name]++
+		for _, action := range step.Actions {
+			k := nodeStepKey{sk, int(action.Index)}
+			if _, ok := runtimes[k]; !ok {
+				keys = append(keys, k)
+			}
+			runtimes[k] = time.Duration(action.Runtime)
+		}
+	}
+	return keys, runtimes
+}
+
+// CompareBuilds lines up the steps of two builds by step name and node. Steps
+// are in the order they ran in b; steps that only ran in a follow the step
+// they came after in a.
+func CompareBuilds(a, b *CircleBuild) []StepComparison {
+	aKeys, aTimes := nodeStepRuntimes(a)
+	bKeys, bTimes := nodeStepRuntimes(b)
+	order := make([]nodeStepKey, len(bKeys))
+	copy(order, bKeys)
+	var prev *nodeStepKey
+	for i, k := range aKeys {
+		if _, ok := bTimes[k]; ok {
+			prev = &aKeys[i]
+			continue
+		}
+		at := 0
+		if prev != nil {
+			for j := range order {
+				if order[j] == *prev {
+					at = j + 1
+					break
+				}
+			}
+		}
+		order = append(order, nodeStepKey{})
+		copy(order[at+1:], order[at:])
+		order[at] = k
+		prev = &aKeys[i]
+	}
+	comparisons := make([]StepComparison, len(order))
+	for i, k := range order {
+		c := StepComparison{Step: k.step.name, Node: k.node, A: -1, B: -1}
+		if d, ok := aTimes[k]; ok {
+			c.InA, c.A = true, d
+		}
+		if d, ok := bTimes[k]; ok {
+			c.InB, c.B = true, d
+		}
+		comparisons[i] = c
+	}
+	return comparisons
+}
@@ -0,0 +1,114 @@
+package circle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func statsBuild(num uint32, runtimes ...time.Duration) *CircleBuild {
+	cb := &CircleBuild{BuildNum: num, Parallel: 1, Status: "success"}
+	names := []string{"checkout", "run", "run"}
+	for i, d := range runtimes {
+		cb.Steps = append(cb.Steps, Step{Name: names[i], Actions: []Action{{Runtime: CircleDuration(d)}}})
+	}
+	return cb
+}
+
+func TestStatisticsDelta(t *testing.T) {
+	prev := statsBuild(10, 2*time.Second, 10*time.Second, 30*time.Second)
+	cur := statsBuild(11, 2*time.Second, 25*time.Second, 26*time.Second)
+	out := cur.StatisticsWithOptions(&StatisticsOptions{Previous: prev, Threshold: 10 * time.Second})
+	lines := strings.Split(out, "\n")
+	if lines[0] != "Compared with build 10" {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[2], "Delta") {
+		t.Errorf("expected Delta column, got %q", lines[2])
+	}
+	want := []string{"0.0s", "+15.0s!", "-4.0s"}
+	for i, w := range want {
+		if line := lines[4+i]; !strings.HasSuffix(line, " "+w) {
+			t.Errorf("step %d: expected delta %q, got %q", i, w, line)
+		}
+	}
+	if !strings.Contains(out, "Total of compared steps") || !strings.Contains(out, "+11.0s!") {
+		t.Errorf("expected total delta, got:\n%s", out)
+	}
+	if plain := cur.Statistics(false); strings.Contains(plain, "Delta") {
+		t.Errorf("Statistics should not compare builds:\n%s", plain)
+	}
+}
+
+func TestCompareBuilds(t *testing.T) {
+	a := &CircleBuild{Steps: []Step{
+		{Name: "checkout", Actions: []Action{{Index: 0, Runtime: CircleDuration(2 * time.Second)}, {Index: 1, Runtime: CircleDuration(3 * time.Second)}}},
+		{Name: "lint", Actions: []Action{{Index: 0, Runtime: CircleDuration(4 * time.Second)}}},
+		{Name: "test", Actions: []Action{{Index: 0, Runtime: CircleDuration(10 * time.Second)}}},
+	}}
+	b := &CircleBuild{Steps: []Step{
+		{Name: "checkout", Actions: []Action{{Index: 0, Runtime: CircleDuration(2 * time.Second)}}},
+		{Name: "restore cache", Actions: []Action{{Index: 0, Runtime: CircleDuration(time.Second)}}},
+		{Name: "test", Actions: []Action{{Index: 0, Runtime: CircleDuration(5 * time.Second)}}},
+	}}
+	got := CompareBuilds(a, b)
+	want := []struct {
+		step           string
+		node           int
+		added, removed bool
+	}{
+		{"checkout", 0, false, false},
+		{"checkout", 1, false, true},
+		{"lint", 0, false, true},
+		{"restore cache", 0, true, false},
+		{"test", 0, false, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d comparisons, got %d: %+v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		c := got[i]
+		if c.Step != w.step || c.Node != w.node || c.Added() != w.added || c.Removed() != w.removed {
+			t.Errorf("comparison %d: got %+v, want %+v", i, c, w)
+		}
+	}
+	test := got[4]
+	if !test.Comparable() || test.Delta() != -5*time.Second || test.Percent() != -50 {
+		t.Errorf("unexpected test comparison: %+v", test)
+	}
+}
+
+func TestFormatDelta(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{12340 * time.Millisecond, "+12.3s"},
+		{-2 * time.Second, "-2.0s"},
+		{65 * time.Second, "+1m05s"},
+		{20 * time.Millisecond, "0.0s"},
+	}
+	for _, tt := range tests {
+		if got := FormatDelta(tt.in); got != tt.want {
+			t.Errorf("FormatDelta(%v): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRuntime(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{4230 * time.Millisecond, "4.2s"},
+		{119600 * time.Millisecond, "2m00s"},
+		{59996 * time.Millisecond, "1m00s"},
+		{61700 * time.Millisecond, "1m02s"},
+		{-1, ""},
+	}
+	for _, tt := range tests {
+		if got := FormatRuntime(tt.in); got != tt.want {
+			t.Errorf("FormatRuntime(%v): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package circle
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// Checkout key types. A deploy key has read access to a single repository; a
+// user key has the same access as the user who created it.
+const (
+	CheckoutKeyDeploy = "deploy-key"
+	CheckoutKeyUser   = "user-key"
+)
+
+// The v1.1 API calls user keys "github-user-key".
+const v11UserKey = "github-user-key"
+
+type CheckoutKey struct {
+	PublicKey   string    `json:"public_key"`
+	Type        string    `json:"type"`
+	Fingerprint string    `json:"fingerprint"`
+	Preferred   bool      `json:"preferred"`
+	Time        time.Time `json:"time"`
+}
+
+type checkoutKeyPage struct {
+	Items         []*CheckoutKey `json:"items"`
+	NextPageToken string         `json:"next_page_token"`
+}
+
+type checkoutKeyRequest struct {
+	Type string `json:"type"`
+}
+
+func normalizeKeyType(k *CheckoutKey) *CheckoutKey {
+	if k.Type == v11UserKey {
+		k.Type = CheckoutKeyUser
+	}
+	return k
+}
+
+// ListCheckoutKeys returns the checkout keys for a project.
+func ListCheckoutKeys(ctx context.Context, host, org, project string) ([]*CheckoutKey, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*CheckoutKey, 0)
+	if p.slug.hasV11() {
+		if err := p.request(ctx, "GET", "/checkout-key", nil, &keys); err != nil {
+			return nil, err
+		}
+	} else {
+		token := ""
+		for {
+			uri := "/checkout-key"
+			if token != "" {
+				uri += "?page-token=" + url.QueryEscape(token)
+			}
+			page := new(checkoutKeyPage)
+			if err := p.request(ctx, "GET", uri, nil, page); err != nil {
+				return nil, err
+			}
+			keys = append(keys, page.Items...)
+			if page.NextPageToken == "" {
+				break
+			}
+			token = page.NextPageToken
+		}
+	}
+	for _, k := range keys {
+		normalizeKeyType(k)
+	}
+	return keys, nil
+}
+
+// CreateCheckoutKey creates a new checkout key for the project. keyType
+// should be CheckoutKeyDeploy or CheckoutKeyUser.
+func CreateCheckoutKey(ctx context.Context, host, org, project, keyType string) (*CheckoutKey, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	if keyType == CheckoutKeyUser && p.slug.hasV11() {
+		keyType = v11UserKey
+	}
+	key := new(CheckoutKey)
+	if err := p.request(ctx, "POST", "/checkout-key", &checkoutKeyRequest{Type: keyType}, key); err != nil {
+		return nil, err
+	}
+	return normalizeKeyType(key), nil
+}
+
+// GetCheckoutKey returns the checkout key with the given fingerprint.
+func GetCheckoutKey(ctx context.Context, host, org, project, fingerprint string) (*CheckoutKey, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	key := new(CheckoutKey)
+	if err := p.request(ctx, "GET", "/checkout-key/"+url.PathEscape(fingerprint), nil, key); err != nil {
+		return nil, err
+	}
+	return normalizeKeyType(key), nil
+}
+
+// DeleteCheckoutKey deletes the checkout key with the given fingerprint.
+func DeleteCheckoutKey(ctx context.Context, host, org, project, fingerprint string) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	return p.request(ctx, "DELETE", "/checkout-key/"+url.PathEscape(fingerprint), nil, nil)
+}
+
+// SSHKey is an additional SSH key that CircleCI adds to the build container,
+// for example to deploy to a server.
+type SSHKey struct {
+	Hostname    string `json:"hostname"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type sshKeySettings struct {
+	SSHKeys []*SSHKey `json:"ssh_keys"`
+}
+
+type sshKeyRequest struct {
+	Hostname    string `json:"hostname"`
+	PrivateKey  string `json:"private_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// ErrSSHKeysV2 is returned by the SSH key functions for projects that are only
+// available through the v2 API, which has no SSH key endpoints.
+var ErrSSHKeysV2 = errors.New("additional SSH keys are only available for GitHub and Bitbucket projects")
+
+// ListSSHKeys returns the additional SSH keys for a project.
+func ListSSHKeys(ctx context.Context, host, org, project string) ([]*SSHKey, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	if !p.slug.hasV11() {
+		return nil, ErrSSHKeysV2
+	}
+	s := new(sshKeySettings)
+	if err := p.request(ctx, "GET", "/settings", nil, s); err != nil {
+		return nil, err
+	}
+	if s.SSHKeys == nil {
+		return []*SSHKey{}, nil
+	}
+	return s.SSHKeys, nil
+}
+
+// AddSSHKey adds a PEM encoded private key to the project. CircleCI uses the
+// key when connecting to hostname, or to any host if hostname is empty.
+func AddSSHKey(ctx context.Context, host, org, project, hostname, privateKey string) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	if !p.slug.hasV11() {
+		return ErrSSHKeysV2
+	}
+	return p.request(ctx, "POST", "/ssh-key", &sshKeyRequest{Hostname: hostname, PrivateKey: privateKey}, nil)
+}
+
+// DeleteSSHKey deletes the additional SSH key with the given hostname and
+// fingerprint.
+func DeleteSSHKey(ctx context.Context, host, org, project, hostname, fingerprint string) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	if !p.slug.hasV11() {
+		return ErrSSHKeysV2
+	}
+	return p.request(ctx, "DELETE", "/ssh-key", &sshKeyRequest{Hostname: hostname, Fingerprint: fingerprint}, nil)
+}
@@ -1,15 +1,13 @@
 package circle
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"net/url"
 	"os"
-	"path"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -18,21 +16,10 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-var client http.Client
-
-// TODO switch all clients to use this
-var v11client *rest.Client
-
-func init() {
-	v11client = rest.NewClient("", "", v11BaseUri)
-	// use Context to set a timeout on this client
-	v11client.Client.Timeout = 0
-}
-
 const VERSION = "0.34"
-const v11BaseUri = "https://circleci.com/api/v1.1/project"
 
 type TreeBuild struct {
+	Branch     string `json:"branch"`
 	BuildNum   int    `json:"build_num"`
 	BuildURL   string `json:"build_url"`
 	CompareURL string `json:"compare"`
@@ -48,6 +35,15 @@ type TreeBuild struct {
 	Username      string         `json:"username"`
 	VCSRevision   string         `json:"vcs_revision"`
 	VCSType       string         `json:"vcs_type"`
+	// Workflows is only set for builds that ran in a 2.0 workflow.
+	Workflows BuildWorkflow `json:"workflows"`
+}
+
+// BuildWorkflow describes the workflow a build ran in.
+type BuildWorkflow struct {
+	JobName      string `json:"job_name"`
+	WorkflowID   string `json:"workflow_id"`
+	WorkflowName string `json:"workflow_name"`
 }
 
 func (tb TreeBuild) Passed() bool {
@@ -66,6 +62,12 @@ func (tb TreeBuild) Failed() bool {
 	return tb.Status == "failed" || tb.Status == "timedout" || tb.Status == "no_tests" || tb.Status == "infrastructure_fail"
 }
 
+// Finished reports whether the build has stopped running, whether or not it
+// succeeded.
+func (tb TreeBuild) Finished() bool {
+	return tb.Passed() || tb.Failed() || tb.Status == "canceled"
+}
+
 type CircleArtifact struct {
 	Path       string `json:"path"`
 	PrettyPath string `json:"pretty_path"`
@@ -74,7 +76,9 @@ type CircleArtifact struct {
 }
 
 type CircleBuild struct {
+	Branch                  string         `json:"branch"`
 	BuildNum                uint32         `json:"build_num"`
+	BuildURL                string         `json:"build_url"`
 	Parallel                uint8          `json:"parallel"`
 	Platform                string         `json:"platform"`
 	PreviousSuccessfulBuild PreviousBuild  `json:"previous_successful_build"`
@@ -84,11 +88,28 @@ type CircleBuild struct {
 	Status                  string         `json:"status"`
 	Steps                   []Step         `json:"steps"`
 	StopTime                types.NullTime `json:"stop_time"`
+	VCSRevision             string         `json:"vcs_revision"`
 	VCSType                 string         `json:"vcs_type"` // "github", "bitbucket"
 	UsageQueuedAt           types.NullTime `json:"usage_queued_at"`
 	Username                string         `json:"username"` // "golang"
 }
 
+// Finished reports whether the build has stopped running, whether or not it
+// succeeded.
+func (cb CircleBuild) Finished() bool {
+	return TreeBuild{Status: cb.Status}.Finished()
+}
+
+// StepNumber returns the step number the output API uses for action, which
+// belongs to cb.Steps[i]. 1.0 builds number steps by their position in
+// cb.Steps; 2.0 builds use the step number on the action.
+func (cb CircleBuild) StepNumber(i int, action Action) int {
+	if cb.Platform == "2.0" {
+		return action.Step
+	}
+	return i
+}
+
 // Failures returns an array of (buildStep, containerID) integers identifying
 // the IDs of container/build step pairs that failed.
 func (cb CircleBuild) Failures() [][2]int {
@@ -96,11 +117,7 @@ func (cb CircleBuild) Failures() [][2]int {
 	for i, step := range cb.Steps {
 		for j, action := range step.Actions {
 			if action.Failed() {
-				if cb.Platform == "2.0" {
-					failures = append(failures, [...]int{action.Step, j})
-				} else {
-					failures = append(failures, [...]int{i, j})
-				}
+				failures = append(failures, [...]int{cb.StepNumber(i, action), j})
 			}
 		}
 	}
@@ -115,37 +132,50 @@ type CircleOutput struct {
 
 type CircleOutputs []*CircleOutput
 
+// FailureTexts returns the console output for each failed action in the
+// build, in the same order as Failures.
 func (cb CircleBuild) FailureTexts(ctx context.Context) ([]string, error) {
 	group, errctx := errgroup.WithContext(ctx)
 	// todo this is not great design
-	token, err := getToken(cb.Username)
+	org, err := getOrganization(cb.Username)
 	if err != nil {
 		return nil, err
 	}
-	// V2: https://circleci.com/api/v1.1/project/github/kevinburke/go-circle/8/output/102/0?allocation-id=59dc1a06c9e77c0001793e56-0-build%2F346CFC34&truncate=400000
-	failures := cb.Failures()
+	type failure struct {
+		step   int
+		action Action
+	}
+	failures := make([]failure, 0)
+	for i, step := range cb.Steps {
+		for _, action := range step.Actions {
+			if action.Failed() {
+				failures = append(failures, failure{step: i, action: action})
+			}
+		}
+	}
 	results := make([]string, len(failures))
-	for i, failure := range failures {
-		failure := failure
+	for i, f := range failures {
+		f := f
 		i := i
 		group.Go(func() error {
-			// URL we are trying to fetch looks like:
-			// https://circleci.com/api/v1.1/project/github/kevinburke/go-circle/11/output/9/0
-			uri := fmt.Sprintf("/%s/%s/%s/%d/output/%d/%d?circle-token=%s", cb.VCSType, cb.Username, cb.RepoName, cb.BuildNum, failure[0], failure[1], token)
-			req, err := v11client.NewRequest("GET", uri, nil)
+			r, err := org.openOutput(errctx, cb, f.step, f.action)
 			if err != nil {
 				return err
 			}
-			req = req.WithContext(errctx)
-			var outputs []*CircleOutput
-			if err := v11client.Do(req, &outputs); err != nil {
-				return err
-			}
-			var message string
-			for i := range outputs {
-				message = message + outputs[i].Message + "\n"
+			defer r.Close()
+			var b strings.Builder
+			for {
+				out, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				b.WriteString(out.Message)
+				b.WriteByte('\n')
 			}
-			results[i] = message
+			results[i] = b.String()
 			return nil
 		})
 	}
@@ -206,27 +236,6 @@ func getArtifactsUri(vcs VCS, org string, project string, build int) string {
 
 type CircleTreeResponse []TreeBuild
 
-func makeRequest(ctx context.Context, method, uri string) (io.ReadCloser, error) {
-	req, err := http.NewRequest(method, uri, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("User-Agent", fmt.Sprintf("circle-command-line-client/%s", VERSION))
-	req = req.WithContext(ctx)
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode >= 400 {
-		// TODO handle build not found, you get back {"message": "Build not found"}
-		return nil, fmt.Errorf("Request failed with status [%d]", resp.StatusCode)
-	}
-
-	return resp.Body, nil
-}
-
 type FollowResponse struct {
 	Following bool `json:"following"`
 	// TODO...
@@ -236,52 +245,118 @@ type VCS string
 
 const VCSTypeGithub VCS = "github"
 const VCSTypeBitbucket VCS = "bitbucket"
-
-func vcsType(host string) (VCS, error) {
+const VCSTypeGitlab VCS = "gitlab"
+
+// VCSTypeCircleCI is used for projects that CircleCI identifies by
+// organization and project ID instead of by their Git path.
+const VCSTypeCircleCI VCS = "circleci"
+
+// vcsType returns the VCS type for the given Git host. hosts maps hosts that
+// CircleCI can't guess, like a GitHub Enterprise install, to their VCS type.
+func vcsType(host string, hosts map[string]VCS) (VCS, error) {
+	for h, vcs := range hosts {
+		if strings.EqualFold(h, host) {
+			return vcs, nil
+		}
+	}
 	switch {
 	case strings.Contains(host, "github.com"):
 		return VCSTypeGithub, nil
 	case strings.Contains(host, "bitbucket.org"):
 		return VCSTypeBitbucket, nil
+	case strings.Contains(host, "gitlab.com"):
+		return VCSTypeGitlab, nil
 	default:
-		return "", fmt.Errorf("can't find VCS type for unknown host %s", host)
+		return "", fmt.Errorf(`can't find VCS type for unknown host %s.
+
+If this is a GitHub Enterprise or Bitbucket Server host, add it to your config:
+
+[vcs_hosts]
+"%s" = "github"
+`, host, host)
 	}
 }
 
-func Enable(ctx context.Context, host string, org string, repoName string) error {
-	token, err := getToken(org)
+// project contains the configuration needed to make API requests for a single
+// CircleCI project.
+type project struct {
+	slug ProjectSlug
+	cfg  organization
+	// org is the name of the organization in the config file. For "circleci"
+	// projects it is not the same as slug.Org.
+	org string
+}
+
+func getProject(host, org, name string) (*project, error) {
+	c, err := getConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	vcs, err := vcsType(host)
+	orgCfg, err := getCaseInsensitiveOrg(org, c.Organizations)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	slug, err := c.projectSlug(host, org, name)
+	if err != nil {
+		return nil, err
 	}
-	uri := fmt.Sprintf("/%s/%s/%s/follow?circle-token=%s", vcs, org, repoName, token)
-	req, err := v11client.NewRequest("POST", uri, nil)
+	return &project{slug: slug, cfg: orgCfg, org: org}, nil
+}
+
+func Enable(ctx context.Context, host string, org string, repoName string) error {
+	p, err := getProject(host, org, repoName)
 	if err != nil {
 		return err
 	}
-	req = req.WithContext(ctx)
+	if !p.slug.hasV11() {
+		return ErrFollowV2
+	}
+	uri := fmt.Sprintf("/%s/%s/%s/follow", p.slug.VCS, p.slug.Org, p.slug.Project)
 	fr := new(FollowResponse)
-	if err := v11client.Do(req, fr); err != nil {
+	if err := makeNewRequest(ctx, p.cfg, "POST", uri, fr); err != nil {
 		return err
 	}
 	if !fr.Following {
-		return errors.New("not following the project")
+		return fmt.Errorf(`CircleCI did not follow %s. Check that the token for %s
+belongs to a user with admin access to the repository, and that CircleCI has
+been authorized to read the %s organization`, p.slug, org, org)
 	}
 	return nil
 }
 
+// Rebuild retries tb. Builds from the v2 API are retried by rerunning the
+// workflow they ran in.
 func Rebuild(ctx context.Context, tb *TreeBuild) error {
-	token, err := getToken(tb.Username)
+	org, err := getOrganization(tb.Username)
 	if err != nil {
 		return err
 	}
+	if !(ProjectSlug{VCS: VCS(tb.VCSType)}).hasV11() {
+		if tb.Workflows.WorkflowID == "" {
+			return fmt.Errorf("can't rebuild build %d: it didn't run in a workflow", tb.BuildNum)
+		}
+		return doRequest(ctx, org, org.v2Base(), "POST", "/workflow/"+tb.Workflows.WorkflowID+"/rerun", struct{}{}, nil)
+	}
 	// https://circleci.com/gh/segmentio/db-service/1488
 	// url we have is https://circleci.com/api/v1.1/project/github/segmentio/db-service/1486/retry
 	uri := fmt.Sprintf("/%s/%s/%s/%d/retry", tb.VCSType, tb.Username, tb.RepoName, tb.BuildNum)
-	return makeNewRequest(ctx, "POST", uri, token, nil)
+	return makeNewRequest(ctx, org, "POST", uri, nil)
+}
+
+type triggerPipelineRequest struct {
+	Branch string `json:"branch"`
+}
+
+// TriggerBuild starts a new build of the latest commit on branch.
+func TriggerBuild(ctx context.Context, host, org, project, branch string) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	if p.slug.hasV11() {
+		return p.request(ctx, "POST", "/tree/"+url.PathEscape(branch), nil, nil)
+	}
+	return p.request(ctx, "POST", "/pipeline", &triggerPipelineRequest{Branch: branch}, nil)
 }
 
 func GetTree(host, org string, project string, branch string) (*CircleTreeResponse, error) {
@@ -289,84 +364,175 @@ func GetTree(host, org string, project string, branch string) (*CircleTreeRespon
 }
 
 func GetTreeContext(ctx context.Context, host, org, project, branch string) (*CircleTreeResponse, error) {
-	token, err := getToken(org)
+	p, err := getProject(host, org, project)
 	if err != nil {
 		return nil, err
 	}
-	vcs, err := vcsType(host)
-	if err != nil {
-		return nil, err
+	if !p.slug.hasV11() {
+		return p.getTreeV2(ctx, branch)
 	}
-	uri := getTreeUri(vcs, org, project, branch)
+	uri := getTreeUri(p.slug.VCS, p.slug.Org, p.slug.Project, branch)
 	cr := new(CircleTreeResponse)
-	if err := makeNewRequest(ctx, "GET", uri, token, cr); err != nil {
+	if err := makeNewRequest(ctx, p.cfg, "GET", uri, cr); err != nil {
 		return nil, err
 	}
 	return cr, nil
 }
 
-func GetBuild(ctx context.Context, host, org string, project string, buildNum int) (*CircleBuild, error) {
-	token, err := getToken(org)
+// historyPageSize is the most builds the v1.1 API returns in one page.
+const historyPageSize = 100
+
+// BuildHistory returns up to limit of the most recent builds on branch, most
+// recent first. If branch is empty, builds on every branch are returned.
+func BuildHistory(ctx context.Context, host, org, project, branch string, limit int) ([]TreeBuild, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	return p.history(ctx, branch, time.Time{}, limit)
+}
+
+// BuildHistorySince is like BuildHistory, but only returns builds that were
+// queued after since.
+func BuildHistorySince(ctx context.Context, host, org, project, branch string, since time.Time, limit int) ([]TreeBuild, error) {
+	p, err := getProject(host, org, project)
 	if err != nil {
 		return nil, err
 	}
-	vcs, err := vcsType(host)
+	return p.history(ctx, branch, since, limit)
+}
+
+// queuedBefore reports whether builds[len(builds)-1] was queued before since,
+// and removes any builds at the end of builds that were.
+func queuedBefore(builds []TreeBuild, since time.Time) ([]TreeBuild, bool) {
+	if since.IsZero() || len(builds) == 0 {
+		return builds, false
+	}
+	done := false
+	for len(builds) > 0 {
+		last := builds[len(builds)-1]
+		if !last.QueuedAt.Valid || !last.QueuedAt.Time.Before(since) {
+			break
+		}
+		builds = builds[:len(builds)-1]
+		done = true
+	}
+	return builds, done
+}
+
+func (p *project) history(ctx context.Context, branch string, since time.Time, limit int) ([]TreeBuild, error) {
+	if !p.slug.hasV11() {
+		return p.historyV2(ctx, branch, since, limit)
+	}
+	path := ""
+	if branch != "" {
+		path = "/tree/" + url.PathEscape(branch)
+	}
+	builds := make([]TreeBuild, 0)
+	for len(builds) < limit {
+		n := limit - len(builds)
+		if n > historyPageSize {
+			n = historyPageSize
+		}
+		var page []TreeBuild
+		uri := fmt.Sprintf("%s?limit=%d&offset=%d", path, n, len(builds))
+		if err := p.request(ctx, "GET", uri, nil, &page); err != nil {
+			return nil, err
+		}
+		builds = append(builds, page...)
+		var done bool
+		builds, done = queuedBefore(builds, since)
+		if done || len(page) < n {
+			break
+		}
+	}
+	return builds, nil
+}
+
+func GetBuild(ctx context.Context, host, org string, project string, buildNum int) (*CircleBuild, error) {
+	p, err := getProject(host, org, project)
 	if err != nil {
 		return nil, err
 	}
-	uri := getBuildUri(vcs, org, project, buildNum)
+	if !p.slug.hasV11() {
+		return p.getBuildV2(ctx, buildNum)
+	}
+	uri := getBuildUri(p.slug.VCS, p.slug.Org, p.slug.Project, buildNum)
 	cb := new(CircleBuild)
-	if err := makeNewRequest(ctx, "GET", uri, token, cb); err != nil {
+	if err := makeNewRequest(ctx, p.cfg, "GET", uri, cb); err != nil {
 		return nil, err
 	}
 	return cb, nil
 }
 
+// GetPreviousSuccessfulBuild fetches the last build before cb that passed. If
+// CircleCI doesn't know of one, it returns nil and no error.
+func GetPreviousSuccessfulBuild(ctx context.Context, host, org, project string, cb *CircleBuild) (*CircleBuild, error) {
+	if cb.PreviousSuccessfulBuild.BuildNum == 0 {
+		return nil, nil
+	}
+	return GetBuild(ctx, host, org, project, cb.PreviousSuccessfulBuild.BuildNum)
+}
+
 func GetArtifactsForBuild(ctx context.Context, host, org string, project string, buildNum int) ([]*CircleArtifact, error) {
-	token, err := getToken(org)
+	p, err := getProject(host, org, project)
 	if err != nil {
 		return []*CircleArtifact{}, err
 	}
-	vcs, err := vcsType(host)
-	if err != nil {
-		return nil, err
+	if !p.slug.hasV11() {
+		return p.artifactsV2(ctx, buildNum)
 	}
-	uri := getArtifactsUri(vcs, org, project, buildNum)
+	uri := getArtifactsUri(p.slug.VCS, p.slug.Org, p.slug.Project, buildNum)
 	var arts []*CircleArtifact
-	if err := makeNewRequest(ctx, "GET", uri, token, &arts); err != nil {
+	if err := makeNewRequest(ctx, p.cfg, "GET", uri, &arts); err != nil {
 		return []*CircleArtifact{}, err
 	}
 	return arts, nil
 }
 
-func DownloadArtifact(ctx context.Context, artifact *CircleArtifact, directory string, org string) error {
-	token, err := getToken(org)
-	if err != nil {
-		return err
+func makeNewRequest(ctx context.Context, o organization, method, uri string, resp interface{}) error {
+	return doRequest(ctx, o, o.v11Base(), method, uri, nil, resp)
+}
+
+func makeV2Request(ctx context.Context, o organization, method, uri string, resp interface{}) error {
+	return doRequest(ctx, o, o.v2Base(), method, uri, nil, resp)
+}
+
+// request makes a request for a path under the project, like "/envvar", using
+// the v1.1 API if the project supports it and the v2 API otherwise. If body is
+// not nil, it is encoded as JSON and sent as the request body.
+func (p *project) request(ctx context.Context, method, path string, body, resp interface{}) error {
+	if p.slug.hasV11() {
+		return doRequest(ctx, p.cfg, p.cfg.v11Base(), method, "/"+p.slug.V1Path()+path, body, resp)
 	}
-	fname := fmt.Sprintf("%d.%s", artifact.NodeIndex, path.Base(artifact.Url))
-	fmt.Fprintf(os.Stderr, "Downloading artifact to %s\n", fname)
-	f, err := os.Create(filepath.Join(directory, fname))
+	return doRequest(ctx, p.cfg, p.cfg.v2Base(), method, "/project/"+p.slug.String()+path, body, resp)
+}
+
+func doRequest(ctx context.Context, o organization, base, method, uri string, body, resp interface{}) error {
+	hc, err := o.httpClient()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	url := fmt.Sprintf("%s?circle-token=%s", artifact.Url, token)
-	body, err := makeRequest(ctx, "GET", url)
-	if err != nil {
-		return err
+	client := rest.NewClient(o.Token, "", base)
+	client.Client = hc
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
 	}
-	defer body.Close()
-	_, copyErr := io.Copy(f, body)
-	return copyErr
-}
-
-func makeNewRequest(ctx context.Context, method, uri, token string, resp interface{}) error {
-	client := rest.NewClient(token, "", v11BaseUri)
-	req, err := client.NewRequest(method, uri, nil)
+	req, err := client.NewRequest(method, uri, r)
 	if err != nil {
 		return err
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	// The v1.1 API accepts the token as a Basic Auth username; the v2 API
+	// wants it in a header.
+	req.Header.Set("Circle-Token", o.Token)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", fmt.Sprintf("go-circle/%s %s", VERSION, req.Header.Get("User-Agent")))
 	req = req.WithContext(ctx)
@@ -374,17 +540,16 @@ func makeNewRequest(ctx context.Context, method, uri, token string, resp interfa
 }
 
 func CancelBuild(ctx context.Context, host, org, project string, buildNum int) (*CircleBuild, error) {
-	token, err := getToken(org)
+	p, err := getProject(host, org, project)
 	if err != nil {
 		return nil, err
 	}
-	vcs, err := vcsType(host)
-	if err != nil {
-		return nil, err
+	if !p.slug.hasV11() {
+		return p.cancelV2(ctx, buildNum)
 	}
-	uri := getCancelUri(vcs, org, project, buildNum)
+	uri := getCancelUri(p.slug.VCS, p.slug.Org, p.slug.Project, buildNum)
 	var cb CircleBuild
-	if err := makeNewRequest(ctx, "POST", uri, token, &cb); err != nil {
+	if err := makeNewRequest(ctx, p.cfg, "POST", uri, &cb); err != nil {
 		return nil, err
 	}
 	return &cb, nil
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"github.com/kevinburke/remoteci"
+)
+
+// archiveWriter adds files to a tar or zip archive.
+type archiveWriter interface {
+	// Add writes a file to the archive. size is -1 if it's unknown.
+	Add(name string, size int64, modTime time.Time, r io.Reader) error
+	Close() error
+}
+
+type tarArchive struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (t *tarArchive) Add(name string, size int64, modTime time.Time, r io.Reader) error {
+	if size < 0 {
+		// tar headers need the size up front, so hold the file in memory.
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		size = int64(len(data))
+		r = bytes.NewReader(data)
+	}
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := io.Copy(t.tw, r)
+	return err
+}
+
+func (t *tarArchive) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (z *zipArchive) Add(name string, size int64, modTime time.Time, r io.Reader) error {
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	w, err := z.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+func (z *zipArchive) Close() error {
+	return z.zw.Close()
+}
+
+// newArchiveWriter returns an archiveWriter for the format named by the
+// extension of filename.
+func newArchiveWriter(w io.Writer, filename string) (archiveWriter, error) {
+	switch {
+	case strings.HasSuffix(filename, ".tar.gz"), strings.HasSuffix(filename, ".tgz"):
+		gz := gzip.NewWriter(w)
+		return &tarArchive{gz: gz, tw: tar.NewWriter(gz)}, nil
+	case strings.HasSuffix(filename, ".zip"):
+		return &zipArchive{zw: zip.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown archive format for %q, use a .tar.gz or .zip extension", filename)
+	}
+}
+
+type manifestArtifact struct {
+	Path    string    `json:"path"`
+	Node    uint8     `json:"node"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	URL     string    `json:"url"`
+}
+
+// archiveManifest is written to manifest.json at the root of the archive.
+type archiveManifest struct {
+	Org       string              `json:"org"`
+	Project   string              `json:"project"`
+	CreatedAt time.Time           `json:"created_at"`
+	Build     *circle.CircleBuild `json:"build"`
+	Artifacts []manifestArtifact  `json:"artifacts"`
+}
+
+// reportingReader passes the number of bytes read through it to a Reporter.
+type reportingReader struct {
+	r        io.Reader
+	name     string
+	n, total int64
+	reporter circle.Reporter
+}
+
+func (r *reportingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	r.reporter.Report(r.name, r.n, r.total)
+	return n, err
+}
+
+// writeArchive streams arts into an archive at filename, without writing them
+// to disk first, followed by a manifest describing the build.
+func writeArchive(ctx context.Context, remote *git.RemoteURL, buildNum int, arts []*circle.CircleArtifact, filename string) (err error) {
+	build, err := circle.GetBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(filename)
+		}
+	}()
+	aw, err := newArchiveWriter(f, filename)
+	if err != nil {
+		return err
+	}
+	progress := newDownloadProgress(os.Stderr, remoteci.IsATTY(os.Stderr), len(arts))
+	progress.Start()
+	manifest := archiveManifest{
+		Org:       remote.Path,
+		Project:   remote.RepoName,
+		CreatedAt: time.Now().UTC(),
+		Build:     build,
+		Artifacts: make([]manifestArtifact, 0, len(arts)),
+	}
+	for _, art := range arts {
+		body, err := circle.OpenArtifact(ctx, art, remote.Path, 0)
+		if err != nil {
+			progress.Stop()
+			return err
+		}
+		modTime := body.ModTime
+		if modTime.IsZero() {
+			modTime = manifest.CreatedAt
+		}
+		r := &reportingReader{r: body, name: art.LocalPath(), total: body.Size, reporter: progress}
+		progress.Report(r.name, 0, r.total)
+		err = aw.Add(art.LocalPath(), body.Size, modTime, r)
+		body.Close()
+		if err != nil {
+			progress.Stop()
+			return fmt.Errorf("adding %s to archive: %v", art.LocalPath(), err)
+		}
+		manifest.Artifacts = append(manifest.Artifacts, manifestArtifact{
+			Path:    art.RelativePath(),
+			Node:    art.NodeIndex,
+			Size:    r.n,
+			ModTime: modTime,
+			URL:     art.Url,
+		})
+	}
+	progress.Stop()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := aw.Add("manifest.json", int64(len(data)), manifest.CreatedAt, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
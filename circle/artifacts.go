@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"golang.org/x/sync/errgroup"
+)
+
+const artifactsUsage = `usage: artifacts <command> [arguments]
+
+Work with the artifacts of a build. Unless a build is specified, commands use
+the latest finished build on the current branch.
+
+The commands are:
+
+	ls [branch]       List artifacts with their node and size.
+	cat <path>        Write an artifact to stdout.
+	diff <a> <b>      Compare the artifacts from two builds.
+	serve [build]     Browse artifacts through a local web server.
+
+Use "circle artifacts <command> -h" for more information about a command.`
+
+const artifactsLsUsage = `usage: artifacts ls [--build N] [--json] [branch]
+
+List the artifacts for a build, with the node that created them and their
+size.`
+
+const artifactsCatUsage = `usage: artifacts cat [--build N] [--branch name] [--node N] <path>
+
+Write an artifact to stdout. path is the artifact's path relative to the
+artifacts directory, or prefixed with the node, like "1/coverage.out".`
+
+// latestFinishedBuild returns the number of the most recent build on branch
+// that is no longer running.
+func latestFinishedBuild(ctx context.Context, remote *git.RemoteURL, branch string) (int, error) {
+	cr, err := circle.GetTreeContext(ctx, remote.Host, remote.Path, remote.RepoName, branch)
+	if err != nil {
+		return 0, err
+	}
+	for _, build := range *cr {
+		if build.Finished() {
+			return build.BuildNum, nil
+		}
+	}
+	return 0, fmt.Errorf("No finished builds on %s, are you sure there are tests for %s/%s?", branch, remote.Path, remote.RepoName)
+}
+
+// resolveBuild returns build if it is set, otherwise the latest finished build
+// on branch, otherwise the latest finished build on the current branch.
+func resolveBuild(ctx context.Context, remote *git.RemoteURL, build int, branch string) (int, error) {
+	if build > 0 {
+		return build, nil
+	}
+	if branch == "" {
+		var err error
+		branch, err = git.CurrentBranch()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return latestFinishedBuild(ctx, remote, branch)
+}
+
+type artifactListing struct {
+	Path string `json:"path"`
+	Node uint8  `json:"node"`
+	// Size is -1 if unknown.
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+// humanSize formats a byte count, like "1.5MB".
+func humanSize(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func doArtifactsLs(args []string) error {
+	flags := flag.NewFlagSet("artifacts ls", flag.ExitOnError)
+	build := flags.Int("build", 0, "Build number (default latest finished build on the branch)")
+	asJSON := flags.Bool("json", false, "Print artifacts as JSON")
+	glob := flags.String("glob", "", "Only list artifacts matching this pattern")
+	node := flags.Int("node", -1, "Only list artifacts from this container")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", artifactsLsUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, *build, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	arts, err = circle.FilterArtifacts(arts, *glob, *node)
+	if err != nil {
+		return err
+	}
+	listings := make([]artifactListing, len(arts))
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, 8)
+	for i, art := range arts {
+		i, art := i, art
+		listings[i] = artifactListing{Path: art.RelativePath(), Node: art.NodeIndex, Size: -1, URL: art.Url}
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			info, err := circle.StatArtifact(errctx, art, remote.Path)
+			if err != nil {
+				// the listing is still useful without a size.
+				return nil
+			}
+			listings[i].Size = info.Size
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].Node != listings[j].Node {
+			return listings[i].Node < listings[j].Node
+		}
+		return listings[i].Path < listings[j].Path
+	})
+	if *asJSON {
+		return printJSON(listings)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tNODE\tSIZE")
+	for _, l := range listings {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", l.Path, l.Node, humanSize(l.Size))
+	}
+	return w.Flush()
+}
+
+// findArtifact returns the artifact with the given path. If node is negative
+// and the path exists on more than one node, findArtifact returns an error.
+func findArtifact(arts []*circle.CircleArtifact, p string, node int) (*circle.CircleArtifact, error) {
+	p = strings.TrimPrefix(p, "/")
+	var matches []*circle.CircleArtifact
+	for _, art := range arts {
+		if node >= 0 && int(art.NodeIndex) != node {
+			continue
+		}
+		if art.RelativePath() == p || art.LocalPath() == p {
+			matches = append(matches, art)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no artifact named %q", p)
+	case 1:
+		return matches[0], nil
+	default:
+		nodes := make([]string, len(matches))
+		for i := range matches {
+			nodes[i] = strconv.Itoa(int(matches[i].NodeIndex))
+		}
+		return nil, fmt.Errorf("%s exists on nodes %s, pick one with --node", p, strings.Join(nodes, ", "))
+	}
+}
+
+func doArtifactsCat(args []string) error {
+	flags := flag.NewFlagSet("artifacts cat", flag.ExitOnError)
+	build := flags.Int("build", 0, "Build number (default latest finished build on the branch)")
+	branch := flags.String("branch", "", "Branch to find the latest build on (default current branch)")
+	node := flags.Int("node", -1, "Container the artifact was created on")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", artifactsCatUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, *build, *branch)
+	if err != nil {
+		return err
+	}
+	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	art, err := findArtifact(arts, flags.Arg(0), *node)
+	if err != nil {
+		return err
+	}
+	body, err := circle.OpenArtifact(ctx, art, remote.Path, 0)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.Copy(os.Stdout, body)
+	return err
+}
+
+func doArtifacts(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", artifactsUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "ls":
+		return doArtifactsLs(args[1:])
+	case "cat":
+		return doArtifactsCat(args[1:])
+	case "diff":
+		return doArtifactsDiff(args[1:])
+	case "serve":
+		return doArtifactsServe(args[1:])
+	case "-h", "--help", "help":
+		fmt.Fprintf(os.Stderr, "%s\n", artifactsUsage)
+		os.Exit(2)
+	}
+	return fmt.Errorf("unknown artifacts command %q. Run \"circle artifacts -h\" for usage", args[0])
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/sync/errgroup"
+)
+
+const artifactsDiffUsage = `usage: artifacts diff [--glob pattern] [--max-diff-size bytes] <build-a> <build-b>
+
+List the artifacts that were added, removed or changed between two builds.
+Artifacts are matched by node and path. For changed text files smaller than
+--max-diff-size, print a unified diff.`
+
+// diffContextLines is the number of unchanged lines to show around a change.
+const diffContextLines = 3
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// splitLines splits s into lines, without their trailing newlines.
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// unifiedDiff returns a unified diff between a and b, or the empty string if
+// they are the same.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	dmp := diffmatchpatch.New()
+	charsA, charsB, lines := dmp.DiffLinesToChars(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(charsA, charsB, false), lines)
+	var all []diffLine
+	for _, d := range diffs {
+		for _, line := range splitLines(d.Text) {
+			all = append(all, diffLine{op: d.Type, text: line})
+		}
+	}
+	var buf bytes.Buffer
+	// lineA and lineB are the 1-based line numbers of all[i] in a and b.
+	lineA, lineB := 1, 1
+	for i := 0; i < len(all); {
+		if all[i].op == diffmatchpatch.DiffEqual {
+			lineA++
+			lineB++
+			i++
+			continue
+		}
+		// all[i] is a change; the hunk starts a few lines before it, and
+		// ends once there are more than 2*diffContextLines unchanged lines
+		// in a row, or at the end of the file.
+		start := i - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		startA, startB := lineA-(i-start), lineB-(i-start)
+		end, equal := i, 0
+		for end < len(all) && equal <= 2*diffContextLines {
+			if all[end].op == diffmatchpatch.DiffEqual {
+				equal++
+			} else {
+				equal = 0
+			}
+			end++
+		}
+		// drop the unchanged lines past the context.
+		if equal > diffContextLines {
+			end -= equal - diffContextLines
+		}
+		countA, countB := 0, 0
+		var hunk bytes.Buffer
+		for _, l := range all[start:end] {
+			switch l.op {
+			case diffmatchpatch.DiffEqual:
+				countA++
+				countB++
+				hunk.WriteString(" ")
+			case diffmatchpatch.DiffDelete:
+				countA++
+				hunk.WriteString("-")
+			case diffmatchpatch.DiffInsert:
+				countB++
+				hunk.WriteString("+")
+			}
+			hunk.WriteString(l.text)
+			hunk.WriteString("\n")
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+		// like diff(1), an empty range starts at the line before it.
+		if countA == 0 {
+			startA--
+		}
+		if countB == 0 {
+			startB--
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+		buf.Write(hunk.Bytes())
+		for ; i < end; i++ {
+			switch all[i].op {
+			case diffmatchpatch.DiffEqual:
+				lineA++
+				lineB++
+			case diffmatchpatch.DiffDelete:
+				lineA++
+			case diffmatchpatch.DiffInsert:
+				lineB++
+			}
+		}
+	}
+	return buf.String()
+}
+
+// limitedBuffer keeps the first max bytes written to it, and discards the
+// rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if l.overflow || l.Len()+len(p) > l.max {
+		l.overflow = true
+		l.Reset()
+		return len(p), nil
+	}
+	return l.Buffer.Write(p)
+}
+
+type artifactContents struct {
+	sum  [sha256.Size]byte
+	data *limitedBuffer
+}
+
+func fetchArtifactContents(ctx context.Context, art *circle.CircleArtifact, org string, maxSize int) (*artifactContents, error) {
+	body, err := circle.OpenArtifact(ctx, art, org, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	h := sha256.New()
+	c := &artifactContents{data: &limitedBuffer{max: maxSize}}
+	if _, err := io.Copy(io.MultiWriter(h, c.data), body); err != nil {
+		return nil, err
+	}
+	copy(c.sum[:], h.Sum(nil))
+	return c, nil
+}
+
+func isText(b []byte) bool {
+	return bytes.IndexByte(b, 0) == -1 && utf8.Valid(b)
+}
+
+func artifactsByPath(arts []*circle.CircleArtifact) map[string]*circle.CircleArtifact {
+	m := make(map[string]*circle.CircleArtifact, len(arts))
+	for _, art := range arts {
+		m[art.LocalPath()] = art
+	}
+	return m
+}
+
+func doArtifactsDiff(args []string) error {
+	flags := flag.NewFlagSet("artifacts diff", flag.ExitOnError)
+	glob := flags.String("glob", "", "Only compare artifacts matching this pattern")
+	maxSize := flags.Int("max-diff-size", 64*1024, "Largest file, in bytes, to print a diff for")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", artifactsDiffUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	buildA, err := strconv.Atoi(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid build number %q", flags.Arg(0))
+	}
+	buildB, err := strconv.Atoi(flags.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid build number %q", flags.Arg(1))
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	var artsA, artsB []*circle.CircleArtifact
+	for _, b := range []struct {
+		num  int
+		arts *[]*circle.CircleArtifact
+	}{{buildA, &artsA}, {buildB, &artsB}} {
+		arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, b.num)
+		if err != nil {
+			return err
+		}
+		*b.arts, err = circle.FilterArtifacts(arts, *glob, -1)
+		if err != nil {
+			return err
+		}
+	}
+	byPathA, byPathB := artifactsByPath(artsA), artifactsByPath(artsB)
+	var added, removed, common []string
+	for p := range byPathB {
+		if _, ok := byPathA[p]; !ok {
+			added = append(added, p)
+		}
+	}
+	for p := range byPathA {
+		if _, ok := byPathB[p]; ok {
+			common = append(common, p)
+		} else {
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(common)
+
+	contentsA := make([]*artifactContents, len(common))
+	contentsB := make([]*artifactContents, len(common))
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, 8)
+	for i, p := range common {
+		i, p := i, p
+		for _, f := range []struct {
+			art *circle.CircleArtifact
+			out []*artifactContents
+		}{{byPathA[p], contentsA}, {byPathB[p], contentsB}} {
+			f := f
+			g.Go(func() error {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				c, err := fetchArtifactContents(errctx, f.art, remote.Path, *maxSize)
+				if err != nil {
+					return err
+				}
+				f.out[i] = c
+				return nil
+			})
+		}
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Comparing artifacts from build %d and build %d\n\n", buildA, buildB)
+	for _, p := range added {
+		fmt.Printf("A  %s\n", p)
+	}
+	for _, p := range removed {
+		fmt.Printf("D  %s\n", p)
+	}
+	var diffs []string
+	unchanged := 0
+	for i, p := range common {
+		a, b := contentsA[i], contentsB[i]
+		if a.sum == b.sum {
+			unchanged++
+			continue
+		}
+		fmt.Printf("M  %s\n", p)
+		if a.data.overflow || b.data.overflow || !isText(a.data.Bytes()) || !isText(b.data.Bytes()) {
+			continue
+		}
+		diffs = append(diffs, unifiedDiff(
+			fmt.Sprintf("%d/%s", buildA, p),
+			fmt.Sprintf("%d/%s", buildB, p),
+			a.data.String(), b.data.String(),
+		))
+	}
+	fmt.Printf("\n%d added, %d removed, %d changed, %d unchanged\n", len(added), len(removed), len(common)-unchanged, unchanged)
+	for _, d := range diffs {
+		fmt.Print("\n" + d)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+var unifiedDiffTests = []struct {
+	name string
+	a, b string
+	want string
+}{
+	{"identical", "1\n2\n3\n", "1\n2\n3\n", ""},
+	{
+		"insertion",
+		"1\n2\n3\n4\n5\n6\n",
+		"1\n2\n3\nx\ny\n4\n5\n6\n",
+		"--- a\n+++ b\n@@ -1,6 +1,8 @@\n 1\n 2\n 3\n+x\n+y\n 4\n 5\n 6\n",
+	},
+	{"insertion into empty file", "", "x\ny\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+	{
+		"deletion",
+		"1\n2\n3\nx\n4\n5\n6\n",
+		"1\n2\n3\n4\n5\n6\n",
+		"--- a\n+++ b\n@@ -1,7 +1,6 @@\n 1\n 2\n 3\n-x\n 4\n 5\n 6\n",
+	},
+	{"deletion of whole file", "x\n", "", "--- a\n+++ b\n@@ -1,1 +0,0 @@\n-x\n"},
+	{
+		"start and end",
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+		"one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+		"--- a\n+++ b\n" +
+			"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+			"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+	},
+	{
+		"overlapping context",
+		"1\n2\n3\n4\n5\n6\n7\n8\n",
+		"1\ntwo\n3\n4\n5\n6\nseven\n8\n",
+		"--- a\n+++ b\n@@ -1,8 +1,8 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n-7\n+seven\n 8\n",
+	},
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	for _, tt := range unifiedDiffTests {
+		if got := unifiedDiff("a", "b", tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
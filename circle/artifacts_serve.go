@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"github.com/pkg/browser"
+)
+
+const artifactsServeUsage = `usage: artifacts serve [--addr host:port] [--open] [build]
+
+Start a web server that lists the artifacts for a build and serves each one,
+adding your CircleCI token to the request, so you can view HTML reports
+without a logged in browser session. Unless a build is specified, serves the
+latest finished build on the current branch.
+
+Anyone who can connect to --addr can read the artifacts, so be careful
+listening on anything but localhost. Requests must use localhost, an IP
+address or the host from --addr in the Host header; others are rejected.`
+
+var artifactIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ .Org }}/{{ .Project }} build {{ .Build }} artifacts</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+td { padding: 0.2em 1em 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{ .Org }}/{{ .Project }} build {{ .Build }}</h1>
+{{- if not .Artifacts }}
+<p>This build has no artifacts.</p>
+{{- else }}
+<table>
+<tr><th>Node</th><th>Path</th></tr>
+{{- range .Artifacts }}
+<tr><td>{{ .NodeIndex }}</td><td><a href="/{{ .LocalPath }}">{{ .RelativePath }}</a></td></tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
+
+type artifactServer struct {
+	org     string
+	project string
+	build   int
+	arts    []*circle.CircleArtifact
+	byPath  map[string]*circle.CircleArtifact
+	// host and port are the host from --addr and the port the server is
+	// listening on.
+	host string
+	port string
+}
+
+func newArtifactServer(addr net.Addr, host, org, project string, build int, arts []*circle.CircleArtifact) (*artifactServer, error) {
+	_, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(arts, func(i, j int) bool {
+		return arts[i].LocalPath() < arts[j].LocalPath()
+	})
+	return &artifactServer{
+		org:     org,
+		project: project,
+		build:   build,
+		arts:    arts,
+		byPath:  artifactsByPath(arts),
+		host:    host,
+		port:    port,
+	}, nil
+}
+
+// allowedHost reports whether a request with the given Host header was meant
+// for the server. A page that uses DNS rebinding to reach the server sends its
+// own domain name, so the only names allowed are localhost and the host from
+// --addr; IP addresses can't be rebound and are always allowed.
+func (s *artifactServer) allowedHost(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host, port = hostport, "80"
+	}
+	if port != s.port {
+		return false
+	}
+	host = strings.TrimSuffix(host, ".")
+	if strings.EqualFold(host, "localhost") || (s.host != "" && strings.EqualFold(host, s.host)) {
+		return true
+	}
+	return net.ParseIP(strings.Trim(host, "[]")) != nil
+}
+
+func (s *artifactServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.allowedHost(r.Host) {
+		http.Error(w, "forbidden: unexpected Host header", http.StatusForbidden)
+		return
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		data := struct {
+			Org       string
+			Project   string
+			Build     int
+			Artifacts []*circle.CircleArtifact
+		}{s.org, s.project, s.build, s.arts}
+		if err := artifactIndexTemplate.Execute(w, data); err != nil {
+			log.Printf("rendering index: %v", err)
+		}
+		return
+	}
+	p := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	art, ok := s.byPath[p]
+	if !ok {
+		// let relative links in HTML reports find their index page.
+		if index, ok := s.byPath[path.Join(p, "index.html")]; ok {
+			http.Redirect(w, r, "/"+index.LocalPath(), http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	body, err := circle.OpenArtifact(r.Context(), art, s.org, 0)
+	if err != nil {
+		log.Printf("%s: %v", p, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+	contentType := body.ContentType
+	if contentType == "" || contentType == "application/octet-stream" {
+		if t := mime.TypeByExtension(path.Ext(p)); t != "" {
+			contentType = t
+		}
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if body.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(body.Size, 10))
+	}
+	if !body.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", body.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if r.Method == "HEAD" {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("%s: %v", p, err)
+	}
+}
+
+func doArtifactsServe(args []string) error {
+	flags := flag.NewFlagSet("artifacts serve", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8080", "Address to listen on")
+	openBrowser := flags.Bool("open", false, "Open the index page in a browser")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", artifactsServeUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	var build int
+	if flags.NArg() > 0 {
+		var err error
+		build, err = strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(0))
+		}
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, build, "")
+	if err != nil {
+		return err
+	}
+	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(*addr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv, err := newArtifactServer(ln.Addr(), host, remote.Path, remote.RepoName, buildNum, arts)
+	if err != nil {
+		return err
+	}
+	u := "http://" + ln.Addr().String() + "/"
+	fmt.Fprintf(os.Stderr, "Serving %d artifacts for build %d at %s\n", len(arts), buildNum, u)
+	if *openBrowser {
+		if err := browser.OpenURL(u); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", u, err)
+		}
+	}
+	return http.Serve(ln, srv)
+}
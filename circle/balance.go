@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const balanceUsage = `usage: balance [--nodes N] [--files] [build]
+
+Show how evenly a build's work was spread over its containers: the time each
+container was busy, the time it sat idle waiting for the slowest container,
+and the container-minutes wasted that way.
+
+If the build stored test results, also suggest a way to split the test files
+between containers that lowers the build's wall clock time. Use --nodes to
+see the effect of changing the build's parallelism. Unless a build is
+specified, uses the latest finished build on the current branch.`
+
+func doBalance(args []string) error {
+	flags := flag.NewFlagSet("balance", flag.ExitOnError)
+	nodes := flags.Int("nodes", 0, "Number of containers to suggest a split for (default the build's parallelism)")
+	files := flags.Bool("files", false, "List the test files assigned to each container")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", balanceUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	var build int
+	if flags.NArg() > 0 {
+		var err error
+		build, err = strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(0))
+		}
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, build, "")
+	if err != nil {
+		return err
+	}
+	cb, err := circle.GetBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	if len(cb.Steps) == 0 {
+		return fmt.Errorf("CircleCI didn't return any steps for build %d", buildNum)
+	}
+	b := cb.Balance()
+	if len(b.Nodes) == 0 {
+		return fmt.Errorf("None of the steps in build %d ran on a container", buildNum)
+	}
+	fmt.Printf("Build %d ran on %d containers\n\n", buildNum, len(b.Nodes))
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tBUSY\tIDLE")
+	for _, node := range b.Nodes {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", node.Node, node.Busy.Round(time.Second), node.Idle.Round(time.Second))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nWall clock: %s. Wasted: %.1f container-minutes (%.0f%% efficient)\n",
+		b.WallClock.Round(time.Second), b.Wasted.Minutes(), 100*b.Efficiency())
+
+	results, err := circle.TestResults(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	items := circle.TestSplitItems(results)
+	if len(items) == 0 {
+		fmt.Printf("\nBuild %d has no test results, so there's no split to suggest. Store them\nwith the store_test_results step.\n", buildNum)
+		return nil
+	}
+	n := *nodes
+	if n <= 0 {
+		n = len(b.Nodes)
+	}
+	var testTime time.Duration
+	for _, item := range items {
+		testTime += item.Time
+	}
+	// Assume the work that isn't running tests, like checking out code and
+	// restoring caches, happens on every container and takes the same time.
+	overhead := (b.Busy - testTime) / time.Duration(len(b.Nodes))
+	if overhead < 0 {
+		overhead = 0
+	}
+	assignments := circle.Rebalance(items, n)
+	var longest time.Duration
+	for _, a := range assignments {
+		if a.Total > longest {
+			longest = a.Total
+		}
+	}
+	predicted := overhead + longest
+	fmt.Printf("\nSuggested split of %d test files over %d containers:\n\n", len(items), n)
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tFILES\tTEST TIME\tTOTAL")
+	for _, a := range assignments {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", a.Node, len(a.Items), a.Total.Round(time.Second), (overhead + a.Total).Round(time.Second))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nPredicted wall clock: %s (%s now)", predicted.Round(time.Second), b.WallClock.Round(time.Second))
+	if predicted < b.WallClock {
+		fmt.Printf(", %s faster", (b.WallClock - predicted).Round(time.Second))
+	}
+	fmt.Printf(". Container time: %.1f minutes (%.1f now)\n", (predicted * time.Duration(n)).Minutes(), (b.Busy + b.Wasted).Minutes())
+	if *files {
+		for _, a := range assignments {
+			fmt.Printf("\nNode %d:\n", a.Node)
+			for _, item := range a.Items {
+				fmt.Printf("    %-60s %s\n", item.Name, item.Time.Round(time.Millisecond))
+			}
+		}
+	}
+	return nil
+}
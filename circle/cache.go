@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const cacheUsage = `usage: cache clear [--rebuild] [branch]
+
+Delete the dependency caches for this project. With --rebuild, start a new
+build on the given branch, or the current branch if none is provided.`
+
+func doCache(args []string) error {
+	if len(args) == 0 || args[0] != "clear" {
+		fmt.Fprintf(os.Stderr, "%s\n", cacheUsage)
+		os.Exit(2)
+	}
+	clearflags := flag.NewFlagSet("cache clear", flag.ExitOnError)
+	rebuild := clearflags.Bool("rebuild", false, "Start a new build after clearing the cache")
+	clearflags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", cacheUsage)
+		clearflags.PrintDefaults()
+	}
+	clearflags.Parse(args[1:])
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	branch := ""
+	if *rebuild {
+		branch, err = getBranchFromArgs(clearflags.Args())
+		if err != nil {
+			return err
+		}
+	}
+	return clearCache(ctx, os.Stdout, remote, branch)
+}
+
+// clearCache clears the project's dependency caches. If branch is not empty,
+// it then starts a new build on branch.
+func clearCache(ctx context.Context, w io.Writer, remote *git.RemoteURL, branch string) error {
+	if err := circle.ClearCache(ctx, remote.Host, remote.Path, remote.RepoName); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Cleared build caches for %s/%s\n", remote.Path, remote.RepoName)
+	if branch == "" {
+		return nil
+	}
+	if err := circle.TriggerBuild(ctx, remote.Host, remote.Path, remote.RepoName, branch); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Started a new build on %s. Run \"circle wait %s\" to follow it.\n", branch, branch)
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	git "github.com/kevinburke/go-git"
+)
+
+func TestClearCache(t *testing.T) {
+	const base = "/v1.1/project/github/kevinburke/go-circle"
+	var mu sync.Mutex
+	requests := make([]string, 0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "DELETE " + base + "/build-cache":
+			w.Write([]byte(`{"status": "build dependency caches deleted"}`))
+		case "POST " + base + "/tree/master":
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	_, cleanup := testConfig(t, s.URL, "")
+	defer cleanup()
+	remote := &git.RemoteURL{Host: "github.com", Path: "kevinburke", RepoName: "go-circle"}
+
+	tests := []struct {
+		name   string
+		branch string
+		want   []string
+	}{
+		{"clear", "", []string{"DELETE " + base + "/build-cache"}},
+		{"rebuild", "master", []string{"DELETE " + base + "/build-cache", "POST " + base + "/tree/master"}},
+	}
+	for _, tt := range tests {
+		requests = requests[:0]
+		var out bytes.Buffer
+		if err := clearCache(context.Background(), &out, remote, tt.branch); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(requests, tt.want) {
+			t.Errorf("%s: requests:\ngot  %q\nwant %q", tt.name, requests, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const compareUsage = `usage: compare [--format text|json|markdown] <build-a> <build-b>
+
+Compare the run time of each step on each node in two builds. Steps are
+matched by name and node; steps that only ran in one of the builds are marked
+as added or removed.`
+
+type stepComparisonJSON struct {
+	Step string `json:"step"`
+	Node int    `json:"node"`
+	// AMillis and BMillis are nil if the step didn't finish in that build.
+	AMillis     *int64   `json:"a_ms"`
+	BMillis     *int64   `json:"b_ms"`
+	DeltaMillis *int64   `json:"delta_ms"`
+	Percent     *float64 `json:"percent"`
+	Change      string   `json:"change,omitempty"`
+}
+
+func millis(d time.Duration) *int64 {
+	ms := int64(d / time.Millisecond)
+	return &ms
+}
+
+// formatStepTime formats a step's run time, or "-" if the step didn't run.
+func formatStepTime(ran bool, d time.Duration) string {
+	switch {
+	case !ran:
+		return "-"
+	case d < 0:
+		return "running"
+	default:
+		return circle.FormatRuntime(d)
+	}
+}
+
+func formatStepDelta(c circle.StepComparison) (delta, percent string) {
+	switch {
+	case c.Added():
+		return "added", ""
+	case c.Removed():
+		return "removed", ""
+	case !c.Comparable():
+		return "", ""
+	}
+	delta = circle.FormatDelta(c.Delta())
+	if c.A > 0 {
+		percent = fmt.Sprintf("%+.1f%%", c.Percent())
+	}
+	return delta, percent
+}
+
+func doCompare(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	format := flags.String("format", "text", "Output format: text, json or markdown")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", compareUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	switch *format {
+	case "text", "json", "markdown":
+	default:
+		return fmt.Errorf("unknown format %q, should be text, json or markdown", *format)
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	nums := make([]int, 2)
+	for i := range nums {
+		var err error
+		nums[i], err = strconv.Atoi(flags.Arg(i))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(i))
+		}
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	builds := make([]*circle.CircleBuild, 2)
+	for i, num := range nums {
+		builds[i], err = circle.GetBuild(ctx, remote.Host, remote.Path, remote.RepoName, num)
+		if err != nil {
+			return err
+		}
+		if len(builds[i].Steps) == 0 {
+			return fmt.Errorf("CircleCI didn't return any steps for build %d", num)
+		}
+	}
+	comparisons := circle.CompareBuilds(builds[0], builds[1])
+	switch *format {
+	case "json":
+		out := make([]stepComparisonJSON, len(comparisons))
+		for i, c := range comparisons {
+			out[i] = stepComparisonJSON{Step: c.Step, Node: c.Node}
+			if c.InA && c.A >= 0 {
+				out[i].AMillis = millis(c.A)
+			}
+			if c.InB && c.B >= 0 {
+				out[i].BMillis = millis(c.B)
+			}
+			switch {
+			case c.Added():
+				out[i].Change = "added"
+			case c.Removed():
+				out[i].Change = "removed"
+			case c.Comparable():
+				out[i].DeltaMillis = millis(c.Delta())
+				if c.A > 0 {
+					pct := c.Percent()
+					out[i].Percent = &pct
+				}
+			}
+		}
+		return printJSON(out)
+	case "markdown":
+		fmt.Printf("| Step | Node | #%d | #%d | Delta | Change |\n", nums[0], nums[1])
+		fmt.Printf("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, c := range comparisons {
+			delta, percent := formatStepDelta(c)
+			step := strings.Replace(strings.Replace(c.Step, "|", `\|`, -1), "\n", " ", -1)
+			fmt.Printf("| %s | %d | %s | %s | %s | %s |\n", step, c.Node,
+				formatStepTime(c.InA, c.A), formatStepTime(c.InB, c.B), delta, percent)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "STEP\tNODE\t#%d\t#%d\tDELTA\tCHANGE\n", nums[0], nums[1])
+	for _, c := range comparisons {
+		delta, percent := formatStepDelta(c)
+		step := strings.Replace(c.Step, "\n", "\\n", -1)
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", step, c.Node,
+			formatStepTime(c.InA, c.A), formatStepTime(c.InB, c.B), delta, percent)
+	}
+	a, b := builds[0], builds[1]
+	if a.StartTime.Valid && a.StopTime.Valid && b.StartTime.Valid && b.StopTime.Valid {
+		c := circle.StepComparison{
+			InA: true, InB: true,
+			A: a.StopTime.Time.Sub(a.StartTime.Time),
+			B: b.StopTime.Time.Sub(b.StartTime.Time),
+		}
+		delta, percent := formatStepDelta(c)
+		fmt.Fprintf(w, "\t\t\t\t\t\nBuild time\t\t%s\t%s\t%s\t%s\n", formatStepTime(true, c.A), formatStepTime(true, c.B), delta, percent)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	"github.com/kevinburke/go-circle/coverage"
+	git "github.com/kevinburke/go-git"
+	"golang.org/x/sync/errgroup"
+)
+
+const coverageUsage = `usage: coverage [--glob pattern] [--base branch] [--merged file] [build]
+
+Download the Go cover profiles that each container uploaded as artifacts,
+merge them, and print the coverage for each package. Unless a build is
+specified, uses the latest finished build on the current branch.
+
+With --base, also print the change in coverage since the last successful
+build on that branch.`
+
+// fetchCoverage downloads the cover profiles for a build that match glob and
+// merges them. Artifacts that match glob but aren't cover profiles are
+// skipped.
+func fetchCoverage(ctx context.Context, remote *git.RemoteURL, buildNum int, glob string) (*coverage.Profile, error) {
+	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return nil, err
+	}
+	arts, err = circle.FilterArtifacts(arts, glob, -1)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	profiles := make([]*coverage.Profile, 0, len(arts))
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, 8)
+	for _, art := range arts {
+		art := art
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			body, err := circle.OpenArtifact(errctx, art, remote.Path, 0)
+			if err != nil {
+				return err
+			}
+			defer body.Close()
+			p, err := coverage.Parse(body)
+			if err == coverage.ErrNotProfile {
+				fmt.Fprintf(os.Stderr, "Skipping %s: not a Go cover profile\n", art.LocalPath())
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", art.LocalPath(), err)
+			}
+			mu.Lock()
+			profiles = append(profiles, p)
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("no cover profiles matching %q in build %d", glob, buildNum)
+	}
+	return coverage.Merge(profiles...)
+}
+
+// lastSuccessfulBuild returns the most recent passing build on branch.
+func lastSuccessfulBuild(ctx context.Context, remote *git.RemoteURL, branch string) (int, error) {
+	cr, err := circle.GetTreeContext(ctx, remote.Host, remote.Path, remote.RepoName, branch)
+	if err != nil {
+		return 0, err
+	}
+	for _, build := range *cr {
+		if build.Passed() {
+			return build.BuildNum, nil
+		}
+	}
+	return 0, fmt.Errorf("no recent successful builds on %s", branch)
+}
+
+func formatDelta(d float64) string {
+	if d > -0.05 && d < 0.05 {
+		return "0.0"
+	}
+	return fmt.Sprintf("%+.1f", d)
+}
+
+func doCoverage(args []string) error {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	glob := flags.String("glob", "*cover*", "Pattern matching the cover profile artifacts")
+	base := flags.String("base", "", "Compare against the last successful build on this branch")
+	merged := flags.String("merged", "", "Write the merged profile to this file")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", coverageUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	var build int
+	if flags.NArg() > 0 {
+		var err error
+		build, err = strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(0))
+		}
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, build, "")
+	if err != nil {
+		return err
+	}
+	profile, err := fetchCoverage(ctx, remote, buildNum, *glob)
+	if err != nil {
+		return err
+	}
+	if *merged != "" {
+		f, err := os.Create(*merged)
+		if err != nil {
+			return err
+		}
+		if _, err := profile.WriteTo(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	var baseCoverage map[string]coverage.PackageCoverage
+	var baseTotal coverage.PackageCoverage
+	if *base != "" {
+		baseNum, err := lastSuccessfulBuild(ctx, remote, *base)
+		if err != nil {
+			return err
+		}
+		baseProfile, err := fetchCoverage(ctx, remote, baseNum, *glob)
+		if err != nil {
+			return fmt.Errorf("getting coverage for %s build %d: %v", *base, baseNum, err)
+		}
+		baseCoverage = make(map[string]coverage.PackageCoverage)
+		for _, c := range baseProfile.Packages() {
+			baseCoverage[c.Package] = c
+		}
+		baseTotal = baseProfile.Total()
+		fmt.Printf("Comparing build %d with build %d on %s\n\n", buildNum, baseNum, *base)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if baseCoverage != nil {
+		fmt.Fprintln(w, "PACKAGE\tCOVERAGE\tBASE\tDELTA")
+	} else {
+		fmt.Fprintln(w, "PACKAGE\tCOVERAGE")
+	}
+	row := func(c, b coverage.PackageCoverage, hasBase bool) {
+		if baseCoverage == nil {
+			fmt.Fprintf(w, "%s\t%.1f%%\n", c.Package, c.Percent())
+		} else if !hasBase {
+			fmt.Fprintf(w, "%s\t%.1f%%\t-\t-\n", c.Package, c.Percent())
+		} else {
+			fmt.Fprintf(w, "%s\t%.1f%%\t%.1f%%\t%s\n", c.Package, c.Percent(), b.Percent(), formatDelta(c.Percent()-b.Percent()))
+		}
+	}
+	for _, c := range profile.Packages() {
+		b, ok := baseCoverage[c.Package]
+		row(c, b, ok)
+	}
+	total := profile.Total()
+	total.Package = "total"
+	row(total, baseTotal, true)
+	return w.Flush()
+}
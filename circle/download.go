@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"github.com/kevinburke/remoteci"
+	"golang.org/x/sync/errgroup"
+)
+
+const downloadUsage = `usage: download-artifacts [flags] <build-num>
+
+Download artifacts for a build. Artifacts are written to a directory for each
+container, so an artifact saved as coverage/coverage.out on the first node
+ends up at 0/coverage/coverage.out.
+
+Downloads are written to a temporary file and renamed when they complete. If
+a download is interrupted, running the command again with the same --output
+directory resumes it.
+
+With --archive, artifacts are streamed into a single .tar.gz or .zip file
+instead, along with a manifest.json describing the build.`
+
+type downloadFlags struct {
+	output       *string
+	glob         *string
+	node         *int
+	concurrency  *int
+	skipExisting *bool
+	archive      *string
+}
+
+func doDownload(flags *flag.FlagSet, opts downloadFlags) error {
+	buildStr := flags.Arg(0)
+	val, err := strconv.Atoi(buildStr)
+	if err != nil {
+		return err
+	}
+	if *opts.concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", *opts.concurrency)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting remote URL for remote %q: %v\n", "origin", err)
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, val)
+	if err != nil {
+		return err
+	}
+	arts, err = circle.FilterArtifacts(arts, *opts.glob, *opts.node)
+	if err != nil {
+		return err
+	}
+	if *opts.archive != "" {
+		if *opts.output != "" {
+			return errors.New("can't use --output and --archive together")
+		}
+		if err := writeArchive(ctx, remote, val, arts, *opts.archive); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d artifacts for build %d to %s\n", len(arts), val, *opts.archive)
+		return nil
+	}
+	dir := *opts.output
+	if dir == "" {
+		dir, err = ioutil.TempDir("", "circle-artifacts")
+		if err != nil {
+			return err
+		}
+	}
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, *opts.concurrency)
+	progress := newDownloadProgress(os.Stderr, remoteci.IsATTY(os.Stderr), len(arts))
+	dlOpts := &circle.DownloadOptions{SkipExisting: *opts.skipExisting, Reporter: progress}
+	progress.Start()
+	var mu sync.Mutex
+	skipped := 0
+	for _, art := range arts {
+		art := art
+		g.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-errctx.Done():
+				return errctx.Err()
+			}
+			defer func() { <-sem }()
+			res, err := circle.DownloadArtifactWithOptions(errctx, art, dir, remote.Path, dlOpts)
+			if err != nil {
+				return err
+			}
+			if res.Skipped {
+				mu.Lock()
+				skipped++
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	err = g.Wait()
+	progress.Stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Wrote %d artifacts for build %d to %s (%d already downloaded)\n", len(arts), val, dir, skipped)
+	} else {
+		fmt.Fprintf(os.Stderr, "Wrote %d artifacts for build %d to %s\n", len(arts), val, dir)
+	}
+	return nil
+}
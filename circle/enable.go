@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const enableUsage = `usage: enable [-h] [--from project.toml]
+
+Turn on CircleCI builds for this project.
+
+With --from, also create a checkout key, set environment variables and apply
+advanced settings described in a TOML file, like this:
+
+	env_file = "circle.env"   # dotenv file, relative to this file
+
+	[env]
+	AWS_REGION = "us-east-1"
+
+	[checkout_key]
+	type = "deploy-key"       # or "user-key"
+
+	[settings]
+	build_fork_prs = false
+	autocancel_builds = true
+	build_prs_only = true
+
+Every step is safe to run again; steps that are already done are skipped.`
+
+// projectSpec is the format of the file passed to "enable --from".
+type projectSpec struct {
+	EnvFile     string            `toml:"env_file"`
+	Env         map[string]string `toml:"env"`
+	CheckoutKey *checkoutKeySpec  `toml:"checkout_key"`
+	Settings    *settingsSpec     `toml:"settings"`
+}
+
+type checkoutKeySpec struct {
+	Type string `toml:"type"`
+}
+
+type settingsSpec struct {
+	BuildForkPRs     *bool `toml:"build_fork_prs"`
+	AutoCancelBuilds *bool `toml:"autocancel_builds"`
+	BuildPRsOnly     *bool `toml:"build_prs_only"`
+}
+
+func readProjectSpec(filename string) (*projectSpec, []*circle.EnvVar, error) {
+	spec := new(projectSpec)
+	md, err := toml.DecodeFile(filename, spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i := range undecoded {
+			keys[i] = undecoded[i].String()
+		}
+		return nil, nil, fmt.Errorf("%s: unknown keys: %s", filename, strings.Join(keys, ", "))
+	}
+	if spec.CheckoutKey != nil {
+		switch spec.CheckoutKey.Type {
+		case "":
+			spec.CheckoutKey.Type = circle.CheckoutKeyDeploy
+		case circle.CheckoutKeyDeploy, circle.CheckoutKeyUser:
+		default:
+			return nil, nil, fmt.Errorf("%s: unknown checkout key type %q, should be %q or %q", filename, spec.CheckoutKey.Type, circle.CheckoutKeyDeploy, circle.CheckoutKeyUser)
+		}
+	}
+	vars := make([]*circle.EnvVar, 0)
+	if spec.EnvFile != "" {
+		envFile := spec.EnvFile
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(filepath.Dir(filename), envFile)
+		}
+		f, err := os.Open(envFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileVars, err := circle.ParseDotenv(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", envFile, err)
+		}
+		vars = append(vars, fileVars...)
+	}
+	names := make([]string, 0, len(spec.Env))
+	for name := range spec.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		replaced := false
+		for _, ev := range vars {
+			if ev.Name == name {
+				ev.Value = spec.Env[name]
+				replaced = true
+			}
+		}
+		if !replaced {
+			vars = append(vars, &circle.EnvVar{Name: name, Value: spec.Env[name]})
+		}
+	}
+	return spec, vars, nil
+}
+
+// stepReporter prints the result of each bootstrap step as it finishes.
+type stepReporter struct {
+	w      *tabwriter.Writer
+	failed int
+	total  int
+}
+
+// skip reports a step that wasn't needed.
+func (r *stepReporter) skip(step string, reason string) {
+	r.total++
+	fmt.Fprintf(r.w, "%s\tskipped: %s\n", step, reason)
+	r.w.Flush()
+}
+
+func (r *stepReporter) report(step string, result string, err error) {
+	r.total++
+	if err != nil {
+		r.failed++
+		fmt.Fprintf(r.w, "%s\tFAILED: %v\n", step, err)
+	} else {
+		fmt.Fprintf(r.w, "%s\tok: %s\n", step, result)
+	}
+	r.w.Flush()
+}
+
+func boolSetting(b *bool) bool {
+	return b != nil && *b
+}
+
+// changedSettings returns the settings in want that differ from have, and the
+// names of those settings.
+func changedSettings(have *circle.ProjectSettings, want *settingsSpec) (*circle.ProjectSettings, []string) {
+	update := new(circle.ProjectSettings)
+	names := make([]string, 0)
+	if want.BuildForkPRs != nil && *want.BuildForkPRs != boolSetting(have.BuildForkPRs) {
+		update.BuildForkPRs = want.BuildForkPRs
+		names = append(names, "build_fork_prs")
+	}
+	if want.AutoCancelBuilds != nil && *want.AutoCancelBuilds != boolSetting(have.AutoCancelBuilds) {
+		update.AutoCancelBuilds = want.AutoCancelBuilds
+		names = append(names, "autocancel_builds")
+	}
+	if want.BuildPRsOnly != nil && *want.BuildPRsOnly != boolSetting(have.BuildPRsOnly) {
+		update.BuildPRsOnly = want.BuildPRsOnly
+		names = append(names, "build_prs_only")
+	}
+	return update, names
+}
+
+// bootstrap applies the project spec in filename to the project at remote,
+// and writes the result of each step to w.
+func bootstrap(ctx context.Context, w io.Writer, remote *git.RemoteURL, filename string) error {
+	spec, vars, err := readProjectSpec(filename)
+	if err != nil {
+		return err
+	}
+	host, org, repo := remote.Host, remote.Path, remote.RepoName
+	r := &stepReporter{w: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)}
+
+	switch err := circle.Enable(ctx, host, org, repo); err {
+	case nil:
+		r.report("follow", "following "+org+"/"+repo, nil)
+	case circle.ErrFollowV2:
+		// v2 projects are built without being followed.
+		r.skip("follow", err.Error())
+	default:
+		r.report("follow", "", err)
+		// Nothing else will work if we can't follow the project.
+		return err
+	}
+
+	if spec.CheckoutKey != nil {
+		keys, err := circle.ListCheckoutKeys(ctx, host, org, repo)
+		if err != nil {
+			r.report("checkout key", "", err)
+		} else {
+			var existing *circle.CheckoutKey
+			for _, k := range keys {
+				if k.Type == spec.CheckoutKey.Type {
+					existing = k
+					break
+				}
+			}
+			if existing != nil {
+				r.report("checkout key", fmt.Sprintf("%s %s already exists", existing.Type, existing.Fingerprint), nil)
+			} else {
+				key, err := circle.CreateCheckoutKey(ctx, host, org, repo, spec.CheckoutKey.Type)
+				if err == nil {
+					r.report("checkout key", fmt.Sprintf("created %s %s", key.Type, key.Fingerprint), nil)
+				} else {
+					r.report("checkout key", "", err)
+				}
+			}
+		}
+	}
+
+	if len(vars) > 0 {
+		current, err := circle.ListEnvVars(ctx, host, org, repo)
+		if err != nil {
+			r.report("env", "", err)
+		} else {
+			values := make(map[string]string, len(vars))
+			for _, ev := range vars {
+				values[ev.Name] = ev.Value
+			}
+			for _, c := range circle.DiffEnvVars(current, vars) {
+				step := "env " + c.Name
+				if c.Action == circle.EnvVarUnchanged {
+					r.report(step, "unchanged", nil)
+					continue
+				}
+				_, err := circle.SetEnvVar(ctx, host, org, repo, c.Name, values[c.Name])
+				if c.Action == circle.EnvVarAdd {
+					r.report(step, "added "+c.New, err)
+				} else {
+					r.report(step, "updated "+c.Old+" -> "+c.New, err)
+				}
+			}
+		}
+	}
+
+	if spec.Settings != nil {
+		have, err := circle.GetProjectSettings(ctx, host, org, repo)
+		if err != nil {
+			r.report("settings", "", err)
+		} else {
+			update, names := changedSettings(have, spec.Settings)
+			if len(names) == 0 {
+				r.report("settings", "unchanged", nil)
+			} else {
+				err := circle.UpdateProjectSettings(ctx, host, org, repo, update)
+				r.report("settings", "updated "+strings.Join(names, ", "), err)
+			}
+		}
+	}
+	if r.failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", r.failed, r.total)
+	}
+	return nil
+}
+
+func doEnable(flags *flag.FlagSet, from string) error {
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return circle.Enable(ctx, remote.Host, remote.Path, remote.RepoName)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	return bootstrap(ctx, os.Stdout, remote, from)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	git "github.com/kevinburke/go-git"
+)
+
+const v2ProjectSpec = `
+[env]
+AWS_REGION = "us-east-1"
+
+[checkout_key]
+type = "deploy-key"
+
+[settings]
+autocancel_builds = true
+`
+
+func TestBootstrapV2(t *testing.T) {
+	var mu sync.Mutex
+	requests := make([]string, 0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v2/project/circleci/org-id/project-id/checkout-key":
+			w.Write([]byte(`{"items": [], "next_page_token": null}`))
+		case "POST /v2/project/circleci/org-id/project-id/checkout-key":
+			w.Write([]byte(`{"type": "deploy-key", "fingerprint": "aa:bb"}`))
+		case "GET /v2/project/circleci/org-id/project-id/envvar":
+			w.Write([]byte(`{"items": [], "next_page_token": null}`))
+		case "POST /v2/project/circleci/org-id/project-id/envvar":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["name"] != "AWS_REGION" || body["value"] != "us-east-1" {
+				t.Errorf("unexpected env var: %v", body)
+			}
+			w.Write([]byte(`{"name": "AWS_REGION", "value": "xxxxst-1"}`))
+		case "GET /v2/project/circleci/org-id/project-id/settings":
+			w.Write([]byte(`{"advanced": {"autocancel_builds": false}}`))
+		case "PATCH /v2/project/circleci/org-id/project-id/settings":
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	dir, cleanup := testConfig(t, s.URL, "circleci/org-id/project-id")
+	defer cleanup()
+	specFile := filepath.Join(dir, "project.toml")
+	if err := ioutil.WriteFile(specFile, []byte(v2ProjectSpec), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &git.RemoteURL{Host: "github.com", Path: "kevinburke", RepoName: "go-circle"}
+	var out bytes.Buffer
+	if err := bootstrap(context.Background(), &out, remote, specFile); err != nil {
+		t.Fatalf("bootstrap: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "follow  skipped:") {
+		t.Errorf("expected follow to be skipped, got:\n%s", out.String())
+	}
+	for _, want := range []string{
+		"POST /v2/project/circleci/org-id/project-id/checkout-key",
+		"POST /v2/project/circleci/org-id/project-id/envvar",
+		"PATCH /v2/project/circleci/org-id/project-id/settings",
+	} {
+		found := false
+		for _, req := range requests {
+			found = found || req == want
+		}
+		if !found {
+			t.Errorf("expected a %s request, got %v", want, requests)
+		}
+	}
+}
+
+// testConfig points XDG_CONFIG_HOME at a temporary directory with a config
+// file that sends requests for the kevinburke org to apiBase. If slug is not
+// empty, github.com/kevinburke/go-circle is mapped to that v2 project slug.
+// Call the returned function to restore the environment.
+func testConfig(t *testing.T, apiBase, slug string) (string, func()) {
+	dir, err := ioutil.TempDir("", "go-circle-cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf("[organizations]\n\n    [organizations.kevinburke]\n    token = \"tok\"\n    api_base = %q\n", apiBase)
+	if slug != "" {
+		cfg += fmt.Sprintf("\n[projects]\n\"github.com/kevinburke/go-circle\" = %q\n", slug)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "circleci"), []byte(cfg), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	old, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	return dir, func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const envUsage = `usage: env [--dry-run] <command> [arguments]
+
+Manage environment variables for this project. CircleCI never returns the full
+value of a variable, so values are printed masked, like "xxxx1234".
+
+The commands are:
+
+	list              List environment variables.
+	get NAME          Print the masked value of NAME.
+	set NAME VALUE    Set NAME to VALUE. If VALUE is "-", read it from stdin.
+	unset NAME        Delete NAME.
+	import FILE       Set every variable in a dotenv FILE ("-" for stdin).
+	export            Print variables in dotenv format, with masked values.
+
+With --dry-run, set, unset and import print what would change without
+changing anything.`
+
+func printEnvChanges(w io.Writer, changes []circle.EnvVarChange) {
+	for _, c := range changes {
+		switch c.Action {
+		case circle.EnvVarAdd:
+			fmt.Fprintf(w, "+ %s=%s\n", c.Name, c.New)
+		case circle.EnvVarUpdate:
+			fmt.Fprintf(w, "~ %s=%s -> %s\n", c.Name, c.Old, c.New)
+		default:
+			fmt.Fprintf(w, "  %s=%s (unchanged)\n", c.Name, c.Old)
+		}
+	}
+}
+
+// readValue returns val, or the contents of stdin if val is "-".
+func readValue(val string) (string, error) {
+	if val != "-" {
+		return val, nil
+	}
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func doEnv(flags *flag.FlagSet, dryRun bool) error {
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	host, org, repo := remote.Host, remote.Path, remote.RepoName
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	switch args[0] {
+	case "list", "export":
+		vars, err := circle.ListEnvVars(ctx, host, org, repo)
+		if err != nil {
+			return err
+		}
+		sort.Slice(vars, func(i, j int) bool {
+			return vars[i].Name < vars[j].Name
+		})
+		if args[0] == "export" {
+			for _, ev := range vars {
+				fmt.Printf("%s=%s\n", ev.Name, ev.Value)
+			}
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVALUE")
+		for _, ev := range vars {
+			fmt.Fprintf(w, "%s\t%s\n", ev.Name, ev.Value)
+		}
+		return w.Flush()
+	case "get":
+		if len(args) != 2 {
+			return errors.New("usage: env get NAME")
+		}
+		ev, err := circle.GetEnvVar(ctx, host, org, repo, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(ev.Value)
+		return nil
+	case "set":
+		if len(args) != 3 {
+			return errors.New("usage: env set NAME VALUE")
+		}
+		val, err := readValue(args[2])
+		if err != nil {
+			return err
+		}
+		desired := []*circle.EnvVar{{Name: args[1], Value: val}}
+		if dryRun {
+			current, err := circle.ListEnvVars(ctx, host, org, repo)
+			if err != nil {
+				return err
+			}
+			printEnvChanges(os.Stdout, circle.DiffEnvVars(current, desired))
+			return nil
+		}
+		ev, err := circle.SetEnvVar(ctx, host, org, repo, args[1], val)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Set %s=%s\n", ev.Name, ev.Value)
+		return nil
+	case "unset":
+		if len(args) != 2 {
+			return errors.New("usage: env unset NAME")
+		}
+		if dryRun {
+			ev, err := circle.GetEnvVar(ctx, host, org, repo, args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("- %s=%s\n", ev.Name, ev.Value)
+			return nil
+		}
+		if err := circle.DeleteEnvVar(ctx, host, org, repo, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", args[1])
+		return nil
+	case "import":
+		if len(args) != 2 {
+			return errors.New("usage: env import FILE")
+		}
+		var r io.Reader = os.Stdin
+		if args[1] != "-" {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		desired, err := circle.ParseDotenv(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", args[1], err)
+		}
+		current, err := circle.ListEnvVars(ctx, host, org, repo)
+		if err != nil {
+			return err
+		}
+		printEnvChanges(os.Stdout, circle.DiffEnvVars(current, desired))
+		if dryRun {
+			return nil
+		}
+		// We can't tell whether a variable changed if its last four
+		// characters are the same, so set all of them.
+		for _, ev := range desired {
+			if _, err := circle.SetEnvVar(ctx, host, org, repo, ev.Name, ev.Value); err != nil {
+				return fmt.Errorf("setting %s: %v", ev.Name, err)
+			}
+		}
+		fmt.Printf("Set %d variables\n", len(desired))
+		return nil
+	default:
+		return fmt.Errorf("unknown env command %q. Run \"circle env -h\" for usage", args[0])
+	}
+}
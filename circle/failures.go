@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	"github.com/kevinburke/go-circle/gotest"
+	"github.com/kevinburke/go-circle/quickfix"
+	git "github.com/kevinburke/go-git"
+)
+
+const failuresUsage = `usage: failures [--format text|quickfix|full] [build]
+
+Print the output of the failed steps in a build. Unless a build is specified,
+uses the latest finished build on the current branch.
+
+The formats are:
+
+	text      Failing Go tests, panics, race reports and build errors, or the
+	          whole output for steps that didn't run go test.
+	quickfix  One "file:line:col: message" line per error, with paths
+	          relative to the root of your checkout. Load it with "vim -q".
+	full      The whole output of each failed step.`
+
+func doFailures(args []string) error {
+	flags := flag.NewFlagSet("failures", flag.ExitOnError)
+	format := flags.String("format", "text", "Output format: text, quickfix or full")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", failuresUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	switch *format {
+	case "text", "quickfix", "full":
+	default:
+		return fmt.Errorf("unknown format %q, should be text, quickfix or full", *format)
+	}
+	var build int
+	if flags.NArg() > 0 {
+		var err error
+		build, err = strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(0))
+		}
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, build, "")
+	if err != nil {
+		return err
+	}
+	cb, err := circle.GetBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	texts, err := cb.FailureTexts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(texts) == 0 {
+		fmt.Fprintf(os.Stderr, "Build %d has no failed steps\n", buildNum)
+		return nil
+	}
+	switch *format {
+	case "quickfix":
+		m, err := quickfix.NewPathMapper(remote)
+		if err != nil {
+			return err
+		}
+		for _, text := range texts {
+			if err := quickfix.Write(os.Stdout, quickfix.Find(text), m); err != nil {
+				return err
+			}
+		}
+	case "full":
+		for _, text := range texts {
+			fmt.Println(text)
+		}
+	default:
+		for _, text := range texts {
+			if _, err := gotest.WriteSummary(os.Stdout, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"golang.org/x/sync/errgroup"
+)
+
+const flakyUsage = `usage: flaky [--branch branch] [--builds 100] [--json]
+
+Find tests that failed without a code change in recent builds on a branch:
+tests that passed and failed on the same revision, for example when a build
+was retried. Tests are ranked by flake rate, then by how recently they flaked.
+
+Tests that failed once in between two passing builds of other revisions are
+listed after them, with the failures in the FLIPS column. These may be flaky,
+or may have been broken by a change that was fixed or reverted right away.
+
+Flaky test detection uses the test results builds store with
+store_test_results. Unless a branch is specified, uses the current branch.`
+
+func doFlaky(args []string) error {
+	flags := flag.NewFlagSet("flaky", flag.ExitOnError)
+	branch := flags.String("branch", "", "Branch to analyze")
+	numBuilds := flags.Int("builds", 100, "Number of recent builds to analyze")
+	asJSON := flags.Bool("json", false, "Print the flaky tests as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", flakyUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *numBuilds <= 0 {
+		return fmt.Errorf("--builds must be positive, got %d", *numBuilds)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	if *branch == "" {
+		*branch, err = git.CurrentBranch()
+		if err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	history, err := circle.BuildHistory(ctx, remote.Host, remote.Path, remote.RepoName, *branch, *numBuilds)
+	if err != nil {
+		return err
+	}
+	builds := make([]circle.BuildTestResults, 0, len(history))
+	for _, tb := range history {
+		// canceled builds didn't get the chance to run every test.
+		if tb.Passed() || tb.Failed() {
+			builds = append(builds, circle.BuildTestResults{Build: tb})
+		}
+	}
+	if len(builds) == 0 {
+		return fmt.Errorf("No finished builds on %s, are you sure there are tests for %s/%s?", *branch, remote.Path, remote.RepoName)
+	}
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, 8)
+	for i := range builds {
+		i := i
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results, err := circle.TestResults(errctx, remote.Host, remote.Path, remote.RepoName, builds[i].Build.BuildNum)
+			if err != nil {
+				return fmt.Errorf("getting test results for build %d: %v", builds[i].Build.BuildNum, err)
+			}
+			builds[i].Results = results
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	withResults := 0
+	for _, b := range builds {
+		if len(b.Results) > 0 {
+			withResults++
+		}
+	}
+	if withResults == 0 {
+		return fmt.Errorf("None of the last %d builds on %s have test results. Store them with the store_test_results step", len(builds), *branch)
+	}
+	flaky := circle.FindFlakyTests(builds)
+	if *asJSON {
+		return printJSON(flaky)
+	}
+	fmt.Fprintf(os.Stderr, "Analyzed %d builds on %s, %d with test results\n\n", len(builds), *branch, withResults)
+	if len(flaky) == 0 {
+		fmt.Println("No flaky tests found")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tJOB\tFLAKES\tRUNS\tRATE\tFLIPS\tLAST FLAKE\tBUILD")
+	for _, f := range flaky {
+		job := f.Job
+		if job == "" {
+			job = "-"
+		}
+		last, build := "-", "-"
+		if f.Flakes > 0 {
+			last = f.LastFlake.Local().Format("2006-01-02 15:04")
+			build = strconv.Itoa(f.LastFlakeBuild)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%.1f%%\t%d\t%s\t%s\n", f.FullName(), job, f.Flakes, f.Runs,
+			100*f.FlakeRate, f.Flips, last, build)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const keysUsage = `usage: keys [--json] <command> [arguments]
+
+Manage checkout keys and additional SSH keys for this project.
+
+The commands are:
+
+	list                        List checkout keys and SSH keys.
+	create [deploy-key|user-key]
+	                            Create a checkout key. Defaults to a deploy key.
+	get FINGERPRINT             Print the public half of a checkout key.
+	delete FINGERPRINT          Delete a checkout key.
+	rotate                      Create a new deploy key, then delete the
+	                            existing deploy keys.
+	add-ssh HOSTNAME FILE       Add the SSH private key in FILE ("-" for stdin)
+	                            for connections to HOSTNAME.
+	delete-ssh HOSTNAME FINGERPRINT
+	                            Delete an SSH key.`
+
+type keyList struct {
+	CheckoutKeys []*circle.CheckoutKey `json:"checkout_keys"`
+	SSHKeys      []*circle.SSHKey      `json:"ssh_keys"`
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func doKeys(flags *flag.FlagSet, asJSON bool) error {
+	args := flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	host, org, repo := remote.Host, remote.Path, remote.RepoName
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	switch args[0] {
+	case "list":
+		keys, err := circle.ListCheckoutKeys(ctx, host, org, repo)
+		if err != nil {
+			return err
+		}
+		sshKeys, err := circle.ListSSHKeys(ctx, host, org, repo)
+		sshKeysV2 := err == circle.ErrSSHKeysV2
+		if err != nil && !sshKeysV2 {
+			return err
+		}
+		if asJSON {
+			return printJSON(keyList{CheckoutKeys: keys, SSHKeys: sshKeys})
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tTYPE/HOST\tFINGERPRINT\tCREATED")
+		for _, k := range keys {
+			typ := k.Type
+			if k.Preferred {
+				typ += " (preferred)"
+			}
+			fmt.Fprintf(w, "checkout\t%s\t%s\t%s\n", typ, k.Fingerprint, k.Time.Format("2006-01-02"))
+		}
+		for _, k := range sshKeys {
+			hostname := k.Hostname
+			if hostname == "" {
+				hostname = "(all hosts)"
+			}
+			fmt.Fprintf(w, "ssh\t%s\t%s\t\n", hostname, k.Fingerprint)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if sshKeysV2 {
+			fmt.Fprintf(os.Stderr, "\nNot listing SSH keys: %v\n", circle.ErrSSHKeysV2)
+		}
+		return nil
+	case "create":
+		keyType := circle.CheckoutKeyDeploy
+		if len(args) > 1 {
+			keyType = args[1]
+		}
+		if keyType != circle.CheckoutKeyDeploy && keyType != circle.CheckoutKeyUser {
+			return fmt.Errorf("unknown key type %q, should be %q or %q", keyType, circle.CheckoutKeyDeploy, circle.CheckoutKeyUser)
+		}
+		key, err := circle.CreateCheckoutKey(ctx, host, org, repo, keyType)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(key)
+		}
+		fmt.Printf("Created %s %s\n", key.Type, key.Fingerprint)
+		return nil
+	case "get":
+		if len(args) != 2 {
+			return errors.New("usage: keys get FINGERPRINT")
+		}
+		key, err := circle.GetCheckoutKey(ctx, host, org, repo, args[1])
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			return printJSON(key)
+		}
+		fmt.Println(key.PublicKey)
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return errors.New("usage: keys delete FINGERPRINT")
+		}
+		if err := circle.DeleteCheckoutKey(ctx, host, org, repo, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted checkout key %s\n", args[1])
+		return nil
+	case "rotate":
+		return rotateDeployKeys(ctx, os.Stdout, host, org, repo)
+	case "add-ssh":
+		if len(args) != 3 {
+			return errors.New("usage: keys add-ssh HOSTNAME FILE")
+		}
+		var data []byte
+		var err error
+		if args[2] == "-" {
+			data, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			data, err = ioutil.ReadFile(args[2])
+		}
+		if err != nil {
+			return err
+		}
+		if err := circle.AddSSHKey(ctx, host, org, repo, args[1], string(data)); err != nil {
+			return err
+		}
+		fmt.Printf("Added SSH key for %s\n", args[1])
+		return nil
+	case "delete-ssh":
+		if len(args) != 3 {
+			return errors.New("usage: keys delete-ssh HOSTNAME FINGERPRINT")
+		}
+		if err := circle.DeleteSSHKey(ctx, host, org, repo, args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted SSH key %s\n", args[2])
+		return nil
+	default:
+		return fmt.Errorf("unknown keys command %q. Run \"circle keys -h\" for usage", args[0])
+	}
+}
+
+// rotateDeployKeys creates a new deploy key for the project, then deletes
+// every other deploy key. The old keys are only deleted once the new one
+// exists, so the project is never left without a way to check out code.
+func rotateDeployKeys(ctx context.Context, w io.Writer, host, org, repo string) error {
+	keys, err := circle.ListCheckoutKeys(ctx, host, org, repo)
+	if err != nil {
+		return err
+	}
+	key, err := circle.CreateCheckoutKey(ctx, host, org, repo, circle.CheckoutKeyDeploy)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Created deploy-key %s\n", key.Fingerprint)
+	for _, k := range keys {
+		if k.Type != circle.CheckoutKeyDeploy || k.Fingerprint == key.Fingerprint {
+			continue
+		}
+		if err := circle.DeleteCheckoutKey(ctx, host, org, repo, k.Fingerprint); err != nil {
+			return fmt.Errorf("deleting old deploy key %s: %v", k.Fingerprint, err)
+		}
+		fmt.Fprintf(w, "Deleted deploy-key %s\n", k.Fingerprint)
+	}
+	return nil
+}
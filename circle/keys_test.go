@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRotateDeployKeys(t *testing.T) {
+	const base = "/v1.1/project/github/kevinburke/go-circle/checkout-key"
+	var mu sync.Mutex
+	requests := make([]string, 0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.Method + " " + r.URL.Path {
+		case "GET " + base:
+			w.Write([]byte(`[{"type": "deploy-key", "fingerprint": "old"}, {"type": "github-user-key", "fingerprint": "user"}]`))
+		case "POST " + base:
+			w.Write([]byte(`{"type": "deploy-key", "fingerprint": "new"}`))
+		case "DELETE " + base + "/old":
+			w.Write([]byte(`{"message": "ok"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+	_, cleanup := testConfig(t, s.URL, "")
+	defer cleanup()
+
+	var out bytes.Buffer
+	if err := rotateDeployKeys(context.Background(), &out, "github.com", "kevinburke", "go-circle"); err != nil {
+		t.Fatal(err)
+	}
+	// The old key must only be deleted after the new one exists, and the
+	// user key must be left alone.
+	want := []string{"GET " + base, "POST " + base, "DELETE " + base + "/old"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests:\ngot  %q\nwant %q", requests, want)
+	}
+	if got := out.String(); got != "Created deploy-key new\nDeleted deploy-key old\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const logsUsage = `usage: logs [--follow] [--step name] [--node N] [build]
+
+Print the console output for a build. Unless a build is specified, uses the
+latest build on the current branch, whether or not it has finished.
+
+With --follow, keep printing output as it arrives until the build finishes.`
+
+// logsPollInterval is how often "logs --follow" checks for new output.
+const logsPollInterval = 3 * time.Second
+
+type actionKey struct {
+	step int
+	node int
+}
+
+// actionLog is the state of the output we've printed for one action.
+type actionLog struct {
+	// printed is the number of bytes of output we've printed.
+	printed int
+	// newline is true if the output we printed ended with a newline.
+	newline bool
+	done    bool
+}
+
+type logFollower struct {
+	remote   *git.RemoteURL
+	build    int
+	stepName string
+	node     int
+	logs     map[actionKey]*actionLog
+	// last is the action we last printed output for, so we only print a
+	// header when the output switches to a different action.
+	last actionKey
+}
+
+func (l *logFollower) matches(step circle.Step, node int) bool {
+	if l.node >= 0 && node != l.node {
+		return false
+	}
+	return l.stepName == "" || strings.Contains(strings.ToLower(step.Name), strings.ToLower(l.stepName))
+}
+
+// printNew prints the output in r that we haven't printed yet. The API has no
+// way to ask for the output after an offset, so the whole log is downloaded
+// each time, but it's decoded one message at a time and the messages we've
+// already printed are skipped instead of being read into memory.
+func (l *logFollower) printNew(r *circle.OutputReader, key actionKey, stepName string, log *actionLog) error {
+	seen := 0
+	for {
+		out, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := out.Message
+		end := seen + len(msg)
+		if end > log.printed {
+			if log.printed > seen {
+				// the message grew since the last poll.
+				msg = msg[log.printed-seen:]
+			}
+			if key != l.last || log.printed == 0 {
+				fmt.Printf("\n==> %s (node %d) <==\n", stepName, key.node)
+				l.last = key
+			}
+			fmt.Print(msg)
+			log.printed = end
+			log.newline = strings.HasSuffix(msg, "\n")
+		}
+		seen = end
+	}
+}
+
+// poll fetches the build and prints any output we haven't printed yet. It
+// returns true once the build has finished and all of its output is printed.
+func (l *logFollower) poll(ctx context.Context) (bool, error) {
+	cb, err := circle.GetBuild(ctx, l.remote.Host, l.remote.Path, l.remote.RepoName, l.build)
+	if err != nil {
+		return false, err
+	}
+	finished := cb.Finished()
+	for i, step := range cb.Steps {
+		for _, action := range step.Actions {
+			node := int(action.Index)
+			if !l.matches(step, node) {
+				continue
+			}
+			key := actionKey{step: cb.StepNumber(i, action), node: node}
+			log, ok := l.logs[key]
+			if !ok {
+				log = new(actionLog)
+				l.logs[key] = log
+			}
+			if log.done || action.Status == "" || action.Status == "not_run" {
+				continue
+			}
+			// check the status before fetching the output, so we don't miss
+			// output written between the two requests.
+			running := action.Status == "running"
+			r, err := cb.OpenOutput(ctx, i, action)
+			if err != nil {
+				return false, err
+			}
+			err = l.printNew(r, key, step.Name, log)
+			r.Close()
+			if err != nil {
+				return false, err
+			}
+			if !running {
+				log.done = true
+				if log.printed > 0 && !log.newline {
+					fmt.Println()
+				}
+			}
+		}
+	}
+	if !finished {
+		return false, nil
+	}
+	for _, log := range l.logs {
+		if !log.done {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// latestBuild returns the most recent build on branch, whether or not it has
+// finished.
+func latestBuild(ctx context.Context, remote *git.RemoteURL, branch string) (int, error) {
+	cr, err := circle.GetTreeContext(ctx, remote.Host, remote.Path, remote.RepoName, branch)
+	if err != nil {
+		return 0, err
+	}
+	if len(*cr) == 0 {
+		return 0, fmt.Errorf("No results, are you sure there are tests for %s/%s?", remote.Path, remote.RepoName)
+	}
+	return (*cr)[0].BuildNum, nil
+}
+
+func doLogs(args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := flags.Bool("follow", false, "Keep printing output until the build finishes")
+	flags.BoolVar(follow, "f", false, "Shorthand for --follow")
+	stepName := flags.String("step", "", "Only print output for steps whose name contains this")
+	node := flags.Int("node", -1, "Only print output from this container")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", logsUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		cancel()
+	}()
+	var build int
+	if flags.NArg() > 0 {
+		build, err = strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(0))
+		}
+	} else {
+		branch, err := git.CurrentBranch()
+		if err != nil {
+			return err
+		}
+		build, err = latestBuild(ctx, remote, branch)
+		if err != nil {
+			return err
+		}
+	}
+	l := &logFollower{
+		remote:   remote,
+		build:    build,
+		stepName: *stepName,
+		node:     *node,
+		logs:     make(map[actionKey]*actionLog),
+		last:     actionKey{step: -1, node: -1},
+	}
+	for {
+		pollCtx, pollCancel := context.WithTimeout(ctx, time.Minute)
+		done, err := l.poll(pollCtx)
+		pollCancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if done || !*follow {
+			return nil
+		}
+		select {
+		case <-time.After(logsPollInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
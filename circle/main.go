@@ -4,7 +4,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/signal"
 	"strconv"
@@ -14,7 +13,6 @@ import (
 	"github.com/kevinburke/go-circle/wait"
 	git "github.com/kevinburke/go-git"
 	"github.com/pkg/browser"
-	"golang.org/x/sync/errgroup"
 )
 
 const help = `The circle binary interacts with a server that runs your tests.
@@ -25,10 +23,24 @@ Usage:
 
 The commands are:
 
+	artifacts           List and print artifacts for a build.
+	balance             Show how evenly a build's work was split between containers.
 	cancel              Cancel the current build.
+	cache               Clear the build caches for this project.
+	compare             Compare the step timings of two builds.
+	coverage            Merge and print Go coverage from a build's artifacts.
 	enable              Enable CircleCI tests for this project.
+	env                 Manage environment variables for this project.
+	failures            Print the failing tests or error locations from a build.
+	flaky               Find flaky tests in recent builds on a branch.
+	keys                Manage checkout keys and SSH keys for this project.
+	logs                Print or follow the console output for a build.
 	open                Open the latest branch build in a browser.
+	queue-stats         Show how long builds wait for a container.
 	rebuild             Rebuild a given test branch.
+	tests               List failing and slow tests from a build's test results.
+	timings             Export test timings for splitting tests between containers.
+	trends              Show how step run times have changed over recent builds.
 	version             Print the current version
 	wait                Wait for tests to finish on a branch.
 	download-artifacts  Download all artifacts.
@@ -36,11 +48,6 @@ The commands are:
 Use "circle help [command]" for more information about a command.
 `
 
-const downloadUsage = `usage: download-artifacts <build-num>`
-const enableUsage = `usage: enable [-h]
-
-Turn on CircleCI builds for this project.`
-
 const cancelUsage = `usage: cancel [-h] [branch]
 
 Cancel the current CircleCI build, or the latest build on the provided 
@@ -113,54 +120,6 @@ func doOpen(flags *flag.FlagSet) {
 	}
 }
 
-func doDownload(flags *flag.FlagSet) error {
-	buildStr := flags.Arg(0)
-	val, err := strconv.Atoi(buildStr)
-	if err != nil {
-		return err
-	}
-	remote, err := git.GetRemoteURL("origin")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting remote URL for remote %q: %v\n", "origin", err)
-		return err
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
-	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, val)
-	if err != nil {
-		return err
-	}
-	g, errctx := errgroup.WithContext(ctx)
-
-	tempDir, err := ioutil.TempDir("", "circle-artifacts")
-	if err != nil {
-		return err
-	}
-	for _, art := range arts {
-		art := art
-		g.Go(func() error {
-			return circle.DownloadArtifact(errctx, art, tempDir, remote.Path)
-		})
-	}
-
-	if err := g.Wait(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return err
-	}
-	fmt.Fprintf(os.Stderr, "Wrote all artifacts for build %d to %s\n", val, tempDir)
-	return nil
-}
-
-func doEnable(flags *flag.FlagSet) error {
-	remote, err := git.GetRemoteURL("origin")
-	if err != nil {
-		return err
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	return circle.Enable(ctx, remote.Host, remote.Path, remote.RepoName)
-}
-
 func doCancel(flags *flag.FlagSet) error {
 	args := flags.Args()
 	branch, err := getBranchFromArgs(args)
@@ -211,8 +170,12 @@ func main() {
 	waitflags := flag.NewFlagSet("wait", flag.ExitOnError)
 	waitRemote := waitflags.String("remote", "origin", "Git remote to use")
 	waitRebase := waitflags.String("rebase", "", "Continually rebase against this remote Git branch")
+	waitFullOutput := waitflags.Bool("full-output", false, "Print all output from failed steps, not just failing Go tests")
+	waitQuickfix := waitflags.String("quickfix", "", "If the build fails, write error locations to this file for \"vim -q\"")
+	waitCompare := waitflags.Bool("compare", false, "Show the change in each step's run time since the previous successful build")
+	waitThreshold := waitflags.Duration("threshold", 10*time.Second, "With --compare, highlight steps that got slower by more than this")
 	waitflags.Usage = func() {
-		fmt.Fprintf(os.Stderr, `usage: wait [--rebase=base-branch] [refspec]
+		fmt.Fprintf(os.Stderr, `usage: wait [--rebase=base-branch] [--full-output] [--quickfix=file] [--compare [--threshold=10s]] [refspec]
 
 Wait for builds to complete, then print a descriptive output on success or
 failure. By default, waits on the current branch, otherwise you can pass a
@@ -222,12 +185,33 @@ branch to wait for.
 		waitflags.PrintDefaults()
 	}
 	enableflags := flag.NewFlagSet("enable", flag.ExitOnError)
+	enableFrom := enableflags.String("from", "", "Bootstrap the project from this TOML file")
 	enableflags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "%s\n\n", enableUsage)
 		enableflags.PrintDefaults()
 	}
+	envflags := flag.NewFlagSet("env", flag.ExitOnError)
+	envDryRun := envflags.Bool("dry-run", false, "Print changes without applying them")
+	envflags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", envUsage)
+		envflags.PrintDefaults()
+	}
+	keysflags := flag.NewFlagSet("keys", flag.ExitOnError)
+	keysJSON := keysflags.Bool("json", false, "Print keys as JSON")
+	keysflags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", keysUsage)
+		keysflags.PrintDefaults()
+	}
 	openflags := flag.NewFlagSet("open", flag.ExitOnError)
 	downloadflags := flag.NewFlagSet("download-artifacts", flag.ExitOnError)
+	downloadOpts := downloadFlags{
+		output:       downloadflags.String("output", "", "Directory to write artifacts to (default a new temporary directory)"),
+		glob:         downloadflags.String("glob", "", "Only download artifacts matching this pattern"),
+		node:         downloadflags.Int("node", -1, "Only download artifacts from this container"),
+		concurrency:  downloadflags.Int("concurrency", 8, "Number of artifacts to download at once"),
+		skipExisting: downloadflags.Bool("skip-existing", true, "Skip artifacts that were already downloaded"),
+		archive:      downloadflags.String("archive", "", "Write artifacts to a .tar.gz or .zip file instead of a directory"),
+	}
 	downloadflags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "%s\n\n", downloadUsage)
 		downloadflags.PrintDefaults()
@@ -253,17 +237,61 @@ Rebuild a given test branch, or the current branch if none is provided.
 		cancelflags.Parse(subargs)
 		err := doCancel(cancelflags)
 		checkError(err)
+	case "artifacts":
+		err := doArtifacts(subargs)
+		checkError(err)
+	case "balance":
+		err := doBalance(subargs)
+		checkError(err)
+	case "cache":
+		err := doCache(subargs)
+		checkError(err)
+	case "compare":
+		err := doCompare(subargs)
+		checkError(err)
+	case "coverage":
+		err := doCoverage(subargs)
+		checkError(err)
 	case "enable":
 		enableflags.Parse(subargs)
-		err := doEnable(enableflags)
+		err := doEnable(enableflags, *enableFrom)
+		checkError(err)
+	case "env":
+		envflags.Parse(subargs)
+		err := doEnv(envflags, *envDryRun)
+		checkError(err)
+	case "failures":
+		err := doFailures(subargs)
+		checkError(err)
+	case "flaky":
+		err := doFlaky(subargs)
+		checkError(err)
+	case "keys":
+		keysflags.Parse(subargs)
+		err := doKeys(keysflags, *keysJSON)
+		checkError(err)
+	case "logs":
+		err := doLogs(subargs)
 		checkError(err)
 	case "open":
 		openflags.Parse(subargs)
 		doOpen(openflags)
+	case "queue-stats":
+		err := doQueueStats(subargs)
+		checkError(err)
 	case "rebuild":
 		rebuildflags.Parse(subargs)
 		err := doRebuild(rebuildflags)
 		checkError(err)
+	case "tests":
+		err := doTests(subargs)
+		checkError(err)
+	case "timings":
+		err := doTimings(subargs)
+		checkError(err)
+	case "trends":
+		err := doTrends(subargs)
+		checkError(err)
 	case "version":
 		fmt.Fprintf(os.Stderr, "circle version %s\n", circle.VERSION)
 		os.Exit(1)
@@ -279,15 +307,20 @@ Rebuild a given test branch, or the current branch if none is provided.
 			<-c
 			cancel()
 		}()
-		err = wait.Wait(ctx, branch, *waitRemote, *waitRebase)
+		err = wait.WaitWithOptions(ctx, branch, *waitRemote, *waitRebase, &wait.Options{
+			FullOutput:   *waitFullOutput,
+			QuickfixFile: *waitQuickfix,
+			Compare:      *waitCompare,
+			Threshold:    *waitThreshold,
+		})
 		checkError(err)
 	case "download-artifacts":
-		if len(args) == 1 {
-			fmt.Fprintf(os.Stderr, "usage: download-artifacts <build-number>\n")
+		downloadflags.Parse(subargs)
+		if downloadflags.NArg() == 0 {
+			downloadflags.Usage()
 			os.Exit(1)
 		}
-		downloadflags.Parse(subargs)
-		err := doDownload(downloadflags)
+		err := doDownload(downloadflags, downloadOpts)
 		checkError(err)
 	default:
 		fmt.Fprintf(os.Stderr, "circle: unknown command %q\n\n", flag.Arg(0))
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// downloadProgress aggregates circle.Reporter updates from concurrent
+// downloads. On a terminal it redraws a progress bar in place; otherwise it
+// prints a line every so often, so CI logs don't fill up with carriage
+// returns.
+type downloadProgress struct {
+	w     io.Writer
+	tty   bool
+	count int // number of artifacts we expect to download
+
+	mu     sync.Mutex
+	n      map[string]int64
+	totals map[string]int64
+	// base is the first byte count reported for each artifact, so resumed
+	// and skipped downloads don't count toward the transfer rate.
+	base  map[string]int64
+	start time.Time
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newDownloadProgress(w io.Writer, tty bool, count int) *downloadProgress {
+	return &downloadProgress{
+		w:      w,
+		tty:    tty,
+		count:  count,
+		n:      make(map[string]int64),
+		totals: make(map[string]int64),
+		base:   make(map[string]int64),
+		done:   make(chan struct{}),
+	}
+}
+
+// Report implements circle.Reporter.
+func (d *downloadProgress) Report(name string, n, total int64) {
+	d.mu.Lock()
+	if _, ok := d.base[name]; !ok {
+		d.base[name] = n
+	}
+	d.n[name] = n
+	d.totals[name] = total
+	d.mu.Unlock()
+}
+
+// Start prints progress every interval until Stop is called.
+func (d *downloadProgress) Start() {
+	interval := 5 * time.Second
+	if d.tty {
+		interval = 200 * time.Millisecond
+	}
+	d.start = time.Now()
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.print()
+			case <-d.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops printing progress. On a terminal it draws the bar one last time
+// and moves to the next line.
+func (d *downloadProgress) Stop() {
+	close(d.done)
+	d.wg.Wait()
+	if d.tty {
+		d.print()
+		fmt.Fprintln(d.w)
+	}
+}
+
+// summary returns the bytes downloaded so far, the bytes transferred since
+// the downloads started, the total size of the artifacts (or -1 if some sizes
+// aren't known yet) and the number of finished artifacts.
+func (d *downloadProgress) summary() (n, transferred, total int64, finished int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, b := range d.n {
+		n += b
+		transferred += b - d.base[name]
+		t := d.totals[name]
+		if t < 0 || total < 0 {
+			total = -1
+		} else {
+			total += t
+		}
+		if t >= 0 && b >= t {
+			finished++
+		}
+	}
+	if len(d.n) < d.count {
+		total = -1
+	}
+	return n, transferred, total, finished
+}
+
+const progressBarWidth = 30
+
+func progressBar(n, total int64) string {
+	filled := 0
+	if total > 0 {
+		filled = int(n * progressBarWidth / total)
+	}
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled) + "]"
+}
+
+func (d *downloadProgress) print() {
+	n, transferred, total, finished := d.summary()
+	rate := "0B/s"
+	if elapsed := time.Since(d.start).Seconds(); elapsed > 0 {
+		rate = humanSize(int64(float64(transferred)/elapsed)) + "/s"
+	}
+	size := humanSize(n)
+	if total >= 0 {
+		size += "/" + humanSize(total)
+	}
+	if d.tty {
+		// \033[K clears the rest of the line, in case it was longer last time.
+		fmt.Fprintf(d.w, "\r%s %s %s, %d/%d artifacts\033[K", progressBar(n, total), size, rate, finished, d.count)
+		return
+	}
+	fmt.Fprintf(d.w, "Downloaded %s (%s), %d/%d artifacts\n", size, rate, finished, d.count)
+}
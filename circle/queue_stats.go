@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	"github.com/kevinburke/go-circle/stats"
+	git "github.com/kevinburke/go-git"
+)
+
+const queueStatsUsage = `usage: queue-stats [--since 7d] [--branch name] [--periods 28]
+
+Show how long builds waited for a container: the distribution of queue times
+by hour of day and day of the week, the number of builds running at once over
+time, and the share of build time spent queued instead of running. Hours and
+days are in local time. Unless a branch is specified, uses builds on every
+branch.`
+
+// writeWaits writes a table row with the number of waits and their p50, p90
+// and max.
+func writeWaits(w io.Writer, label string, waits []time.Duration) {
+	if len(waits) == 0 {
+		fmt.Fprintf(w, "%s\t0\t-\t-\t-\n", label)
+		return
+	}
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", label, len(waits),
+		roundRuntime(stats.DurationPercentile(waits, 50)),
+		roundRuntime(stats.DurationPercentile(waits, 90)),
+		roundRuntime(stats.DurationPercentile(waits, 100)))
+}
+
+func doQueueStats(args []string) error {
+	flags := flag.NewFlagSet("queue-stats", flag.ExitOnError)
+	sinceFlag := flags.String("since", "7d", "How far back to look, like 7d, 2w or 36h")
+	branch := flags.String("branch", "", "Only use builds on this branch")
+	maxBuilds := flags.Int("builds", 2000, "Maximum number of builds to read")
+	periods := flags.Int("periods", 28, "Number of periods to show concurrency for")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", queueStatsUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	span, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+	if *periods < 1 {
+		return fmt.Errorf("--periods must be positive, got %d", *periods)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	now := time.Now()
+	since := now.Add(-span)
+	builds, err := circle.BuildHistorySince(ctx, remote.Host, remote.Path, remote.RepoName, *branch, since, *maxBuilds)
+	if err != nil {
+		return err
+	}
+	q := circle.NewQueueStats(builds, time.Local)
+	if q.Builds == 0 {
+		return fmt.Errorf("No builds with queue times in the last %s", *sinceFlag)
+	}
+	where := "on every branch"
+	if *branch != "" {
+		where = "on " + *branch
+	}
+	fmt.Printf("%d builds %s since %s\n\n", q.Builds, where, since.Format("2006-01-02 15:04"))
+	fmt.Printf("Queued %s (%.1f%%), running %s (%.1f%%) of build time\n",
+		q.Queued.Round(time.Minute), 100*q.QueuedShare(),
+		q.Running.Round(time.Minute), 100*(1-q.QueuedShare()))
+
+	fmt.Printf("\nQueue time by hour of day:\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "HOUR\tBUILDS\tP50\tP90\tMAX")
+	for hour, waits := range q.ByHour {
+		writeWaits(w, fmt.Sprintf("%02d:00", hour), waits)
+	}
+	writeWaits(w, "all", q.Waits)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nQueue time by day of the week:\n\n")
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "DAY\tBUILDS\tP50\tP90\tMAX")
+	// start the week on Monday.
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		writeWaits(w, day.String(), q.ByWeekday[day])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	width := span / time.Duration(*periods)
+	samples := circle.Concurrency(builds, since, now, width)
+	peak := 0
+	maxes := make([]float64, len(samples))
+	for i, s := range samples {
+		maxes[i] = float64(s.Max)
+		if s.Max > peak {
+			peak = s.Max
+		}
+	}
+	fmt.Printf("\nBuilds running at once, per %s:\n\n", width.Round(time.Minute))
+	fmt.Printf("  %s  (peak %d)\n\n", stats.Sparkline(maxes), peak)
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PERIOD\tMAX\tMEAN\t")
+	for _, s := range samples {
+		bar := ""
+		if peak > 0 {
+			bar = strings.Repeat("#", int(s.Mean*20/float64(peak)+0.5))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%s\n", s.Start.Local().Format("Mon Jan 2 15:04"), s.Max, s.Mean, bar)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+)
+
+const testsUsage = `usage: tests [--failed] [--slowest N] [build]
+
+Print the failing and slowest tests in a build, from the test results the
+build stored with store_test_results. Unless a build is specified, uses the
+latest finished build on the current branch.`
+
+func doTests(args []string) error {
+	flags := flag.NewFlagSet("tests", flag.ExitOnError)
+	failed := flags.Bool("failed", false, "Only print failing tests")
+	slowest := flags.Int("slowest", 10, "Number of slow tests to print")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", testsUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	var build int
+	if flags.NArg() > 0 {
+		var err error
+		build, err = strconv.Atoi(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf("invalid build number %q", flags.Arg(0))
+		}
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	buildNum, err := resolveBuild(ctx, remote, build, "")
+	if err != nil {
+		return err
+	}
+	results, err := circle.TestResults(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("build %d has no test results. Store them with the store_test_results step", buildNum)
+	}
+	failures := circle.FailedTests(results)
+	fmt.Printf("Build %d: %d tests, %d failed\n", buildNum, len(results), len(failures))
+	if len(failures) > 0 {
+		fmt.Printf("\n%s", circle.TestFailureSummary(results))
+	}
+	if *failed || *slowest <= 0 {
+		return nil
+	}
+	fmt.Printf("\nSlowest tests:\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tRESULT\tTIME")
+	for _, t := range circle.SlowestTests(results, *slowest) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.FullName(), t.Result, t.Duration().Round(time.Millisecond))
+	}
+	return w.Flush()
+}
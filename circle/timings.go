@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	git "github.com/kevinburke/go-git"
+	"golang.org/x/sync/errgroup"
+)
+
+const timingsUsage = `usage: timings <command> [arguments]
+
+Work with the test timings of recent builds.
+
+The commands are:
+
+	export            Write a timings file for splitting tests.
+
+Use "circle timings <command> -h" for more information about a command.`
+
+const timingsExportUsage = `usage: timings export [--branch name] [--builds N] [--format junit|json] [--smoothing median|p90] [--output file]
+
+Combine the run times of each test and each test file over recent builds into
+a timings file. The junit format can be read by "circleci tests split
+--split-by=timings"; the json format lists tests and files with their run
+times in seconds.
+
+Run times come from the test results builds store with store_test_results.
+For builds without stored results, JUnit XML artifacts matching --glob are
+used instead; artifacts that aren't JUnit reports, or can't be downloaded,
+are skipped. Unless a branch is specified, uses the current branch.`
+
+// fetchJUnitArtifacts downloads and parses the JUnit reports among the
+// artifacts for a build that match glob. Artifacts that aren't JUnit reports
+// are skipped, and so are artifacts that can't be downloaded or parsed, after
+// printing a warning.
+func fetchJUnitArtifacts(ctx context.Context, remote *git.RemoteURL, buildNum int, glob string) ([]*circle.TestResult, error) {
+	arts, err := circle.GetArtifactsForBuild(ctx, remote.Host, remote.Path, remote.RepoName, buildNum)
+	if err != nil {
+		return nil, err
+	}
+	arts, err = circle.FilterArtifacts(arts, glob, -1)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*circle.TestResult, 0)
+	for _, art := range arts {
+		body, err := circle.OpenArtifact(ctx, art, remote.Path, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "Skipping build %d artifact %s: %v\n", buildNum, art.LocalPath(), err)
+			continue
+		}
+		artResults, err := circle.ParseJUnit(body)
+		body.Close()
+		if err == circle.ErrNotJUnit {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping build %d artifact %s: %v\n", buildNum, art.LocalPath(), err)
+			continue
+		}
+		results = append(results, artResults...)
+	}
+	return results, nil
+}
+
+func doTimingsExport(args []string) error {
+	flags := flag.NewFlagSet("timings export", flag.ExitOnError)
+	branch := flags.String("branch", "", "Branch to read builds from")
+	numBuilds := flags.Int("builds", 20, "Number of recent builds to read timings from")
+	format := flags.String("format", "junit", "Output format: junit or json")
+	smoothing := flags.String("smoothing", "median", "Run time to use for each test: median or p90")
+	glob := flags.String("glob", "*.xml", "Pattern matching JUnit artifacts, for builds without stored test results")
+	output := flags.String("output", "", "Write the timings to this file instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", timingsExportUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	var percentile float64
+	switch *smoothing {
+	case "median":
+		percentile = 50
+	case "p90":
+		percentile = 90
+	default:
+		return fmt.Errorf("unknown smoothing %q, should be median or p90", *smoothing)
+	}
+	var write func(*circle.Timings, io.Writer) error
+	switch *format {
+	case "junit":
+		write = (*circle.Timings).WriteJUnit
+	case "json":
+		write = (*circle.Timings).WriteJSON
+	default:
+		return fmt.Errorf("unknown format %q, should be junit or json", *format)
+	}
+	if *numBuilds <= 0 {
+		return fmt.Errorf("--builds must be positive, got %d", *numBuilds)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	if *branch == "" {
+		*branch, err = git.CurrentBranch()
+		if err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	history, err := circle.BuildHistory(ctx, remote.Host, remote.Path, remote.RepoName, *branch, *numBuilds)
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	builds := make([][]*circle.TestResult, 0, len(history))
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, 8)
+	for _, tb := range history {
+		tb := tb
+		if !tb.Passed() && !tb.Failed() {
+			continue
+		}
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results, err := circle.TestResults(errctx, remote.Host, remote.Path, remote.RepoName, tb.BuildNum)
+			if err != nil {
+				return fmt.Errorf("getting test results for build %d: %v", tb.BuildNum, err)
+			}
+			if len(results) == 0 && *glob != "" {
+				results, err = fetchJUnitArtifacts(errctx, remote, tb.BuildNum, *glob)
+				if err != nil {
+					if errctx.Err() != nil {
+						return errctx.Err()
+					}
+					// one build's artifacts shouldn't stop the export.
+					fmt.Fprintf(os.Stderr, "Skipping build %d: getting JUnit artifacts: %v\n", tb.BuildNum, err)
+					return nil
+				}
+			}
+			mu.Lock()
+			builds = append(builds, results)
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	timings := circle.AggregateTimings(builds, percentile)
+	if timings.Builds == 0 {
+		return fmt.Errorf("None of the last %d builds on %s have test results", len(history), *branch)
+	}
+	fmt.Fprintf(os.Stderr, "Timings for %d tests in %d files from %d builds on %s\n", len(timings.Tests), len(timings.Files), timings.Builds, *branch)
+	if *output == "" {
+		return write(timings, os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(timings, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func doTimings(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "%s\n", timingsUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "export":
+		return doTimingsExport(args[1:])
+	case "-h", "--help", "help":
+		fmt.Fprintf(os.Stderr, "%s\n", timingsUsage)
+		os.Exit(2)
+	}
+	return fmt.Errorf("unknown timings command %q. Run \"circle timings -h\" for usage", args[0])
+}
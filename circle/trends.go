@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	circle "github.com/kevinburke/go-circle"
+	"github.com/kevinburke/go-circle/stats"
+	git "github.com/kevinburke/go-git"
+	"golang.org/x/sync/errgroup"
+)
+
+const trendsUsage = `usage: trends [--branch name] [--since 30d] [--step name] [--width 30]
+
+Show how long each step has taken in the successful builds on a branch: the
+median, 90th percentile and longest run time, the change in the median from
+the older half of the builds to the newer half, and a sparkline of the median
+over time. Unless a branch is specified, uses the current branch.
+
+Finished builds are cached in $XDG_CACHE_HOME/go-circle (or ~/.cache/go-circle),
+so only new builds are fetched the next time.`
+
+// parseSince parses a time span like "30d", "2w" or "36h".
+func parseSince(s string) (time.Duration, error) {
+	if len(s) > 1 {
+		var unit time.Duration
+		switch s[len(s)-1] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+		if unit > 0 {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			if err == nil && n > 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid time span %q, should be like 30d, 2w or 36h", s)
+	}
+	return d, nil
+}
+
+// fetchBuilds gets the details of each build in history through the build
+// cache.
+func fetchBuilds(ctx context.Context, remote *git.RemoteURL, history []circle.TreeBuild) ([]*circle.CircleBuild, error) {
+	cache := circle.DefaultBuildCache()
+	builds := make([]*circle.CircleBuild, len(history))
+	g, errctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, 8)
+	for i := range history {
+		i := i
+		g.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			cb, err := cache.GetBuild(errctx, remote.Host, remote.Path, remote.RepoName, history[i].BuildNum)
+			if err != nil {
+				return fmt.Errorf("getting build %d: %v", history[i].BuildNum, err)
+			}
+			builds[i] = cb
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return builds, nil
+}
+
+// halfChange returns the percent change in the median run time from the older
+// half of the samples to the newer half.
+func halfChange(trend *circle.StepTrend) (float64, bool) {
+	n := len(trend.Samples)
+	if n < 4 {
+		return 0, false
+	}
+	older := &circle.StepTrend{Samples: trend.Samples[:n/2]}
+	newer := &circle.StepTrend{Samples: trend.Samples[n-n/2:]}
+	before := older.Percentile(50)
+	if before <= 0 {
+		return 0, false
+	}
+	return 100 * float64(newer.Percentile(50)-before) / float64(before), true
+}
+
+func roundRuntime(d time.Duration) time.Duration {
+	if d >= time.Minute {
+		return d.Round(time.Second)
+	}
+	return d.Round(100 * time.Millisecond)
+}
+
+func doTrends(args []string) error {
+	flags := flag.NewFlagSet("trends", flag.ExitOnError)
+	branch := flags.String("branch", "", "Branch to read builds from")
+	sinceFlag := flags.String("since", "30d", "How far back to look, like 30d, 2w or 36h")
+	stepFlag := flags.String("step", "", "Only show steps whose name contains this")
+	maxBuilds := flags.Int("builds", 500, "Maximum number of builds to read")
+	width := flags.Int("width", 30, "Number of points in each sparkline")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s\n\n", trendsUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	span, err := parseSince(*sinceFlag)
+	if err != nil {
+		return err
+	}
+	if *width < 1 {
+		return fmt.Errorf("--width must be positive, got %d", *width)
+	}
+	remote, err := git.GetRemoteURL("origin")
+	if err != nil {
+		return err
+	}
+	if *branch == "" {
+		*branch, err = git.CurrentBranch()
+		if err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	now := time.Now()
+	since := now.Add(-span)
+	history, err := circle.BuildHistorySince(ctx, remote.Host, remote.Path, remote.RepoName, *branch, since, *maxBuilds)
+	if err != nil {
+		return err
+	}
+	passed := make([]circle.TreeBuild, 0, len(history))
+	for _, tb := range history {
+		// failed builds stop partway through, which would skew the run times.
+		if tb.Passed() {
+			passed = append(passed, tb)
+		}
+	}
+	if len(passed) == 0 {
+		return fmt.Errorf("No successful builds on %s in the last %s", *branch, *sinceFlag)
+	}
+	builds, err := fetchBuilds(ctx, remote, passed)
+	if err != nil {
+		return err
+	}
+	trends := circle.StepTrends(builds)
+	if *stepFlag != "" {
+		filtered := trends[:0]
+		for _, trend := range trends {
+			if strings.Contains(strings.ToLower(trend.Name), strings.ToLower(*stepFlag)) {
+				filtered = append(filtered, trend)
+			}
+		}
+		trends = filtered
+	}
+	if len(trends) == 0 {
+		if *stepFlag != "" {
+			return fmt.Errorf("No steps matching %q in %d builds on %s", *stepFlag, len(builds), *branch)
+		}
+		return fmt.Errorf("CircleCI didn't return any steps for the builds on %s", *branch)
+	}
+	fmt.Printf("%d successful builds on %s since %s\n\n", len(builds), *branch, since.Format("2006-01-02"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tBUILDS\tP50\tP90\tMAX\tCHANGE\tTREND")
+	for _, trend := range trends {
+		name := strings.Replace(trend.Name, "\n", "\\n", -1)
+		if len(name) > 45 {
+			name = name[:44] + "…"
+		}
+		change := "-"
+		if pct, ok := halfChange(trend); ok {
+			change = fmt.Sprintf("%+.1f%%", pct)
+		}
+		buckets := trend.Buckets(since, now, *width, 50)
+		values := make([]float64, len(buckets))
+		for i, b := range buckets {
+			values[i] = float64(b)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", name, len(trend.Samples),
+			roundRuntime(trend.Percentile(50)), roundRuntime(trend.Percentile(90)),
+			roundRuntime(trend.Percentile(100)), change, stats.Sparkline(values))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,247 @@
+// Package coverage parses and merges Go cover profiles, like the ones written
+// by "go test -coverprofile".
+package coverage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotProfile is returned by Parse if the input doesn't start with a "mode:"
+// line.
+var ErrNotProfile = errors.New("coverage: not a Go cover profile")
+
+// Block is a range of statements in a source file.
+type Block struct {
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+	Count     int
+}
+
+func (b Block) less(o Block) bool {
+	if b.StartLine != o.StartLine {
+		return b.StartLine < o.StartLine
+	}
+	if b.StartCol != o.StartCol {
+		return b.StartCol < o.StartCol
+	}
+	if b.EndLine != o.EndLine {
+		return b.EndLine < o.EndLine
+	}
+	return b.EndCol < o.EndCol
+}
+
+func (b Block) samePosition(o Block) bool {
+	return b.StartLine == o.StartLine && b.StartCol == o.StartCol &&
+		b.EndLine == o.EndLine && b.EndCol == o.EndCol
+}
+
+// Profile is the coverage data for a set of files. Mode is "set", "count" or
+// "atomic". Files maps a file name, like
+// "github.com/kevinburke/go-circle/circle.go", to its blocks, sorted by
+// position.
+type Profile struct {
+	Mode  string
+	Files map[string][]Block
+}
+
+// Parse reads a cover profile from r. If a block appears more than once, as
+// happens when tests in several packages cover the same code, the counts are
+// combined.
+func Parse(r io.Reader) (*Profile, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotProfile
+	}
+	first := strings.TrimSpace(s.Text())
+	if !strings.HasPrefix(first, "mode: ") {
+		return nil, ErrNotProfile
+	}
+	p := &Profile{Mode: strings.TrimPrefix(first, "mode: "), Files: make(map[string][]Block)}
+	switch p.Mode {
+	case "set", "count", "atomic":
+	default:
+		return nil, fmt.Errorf("coverage: unknown mode %q", p.Mode)
+	}
+	lineno := 1
+	for s.Scan() {
+		lineno++
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		file, b, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("coverage: line %d: %v", lineno, err)
+		}
+		p.Files[file] = append(p.Files[file], b)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	for file, blocks := range p.Files {
+		p.Files[file] = p.mergeBlocks(blocks)
+	}
+	return p, nil
+}
+
+// parseLine parses a line like "a/b.go:10.2,12.16 3 1".
+func parseLine(line string) (string, Block, error) {
+	var b Block
+	colon := strings.LastIndexByte(line, ':')
+	if colon == -1 {
+		return "", b, fmt.Errorf("invalid line %q", line)
+	}
+	file := line[:colon]
+	fields := strings.Fields(line[colon+1:])
+	if len(fields) != 3 {
+		return "", b, fmt.Errorf("invalid line %q", line)
+	}
+	var err error
+	if _, err = fmt.Sscanf(fields[0], "%d.%d,%d.%d", &b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol); err != nil {
+		return "", b, fmt.Errorf("invalid position %q", fields[0])
+	}
+	if b.NumStmt, err = strconv.Atoi(fields[1]); err != nil {
+		return "", b, fmt.Errorf("invalid statement count %q", fields[1])
+	}
+	if b.Count, err = strconv.Atoi(fields[2]); err != nil {
+		return "", b, fmt.Errorf("invalid count %q", fields[2])
+	}
+	return file, b, nil
+}
+
+// combine returns the count for a block that was hit a times in one profile
+// and b times in another.
+func (p *Profile) combine(a, b int) int {
+	if p.Mode == "set" {
+		if a > 0 || b > 0 {
+			return 1
+		}
+		return 0
+	}
+	return a + b
+}
+
+// mergeBlocks sorts blocks and combines the counts of blocks with the same
+// position.
+func (p *Profile) mergeBlocks(blocks []Block) []Block {
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].less(blocks[j]) })
+	merged := blocks[:0]
+	for _, b := range blocks {
+		if n := len(merged); n > 0 && merged[n-1].samePosition(b) {
+			merged[n-1].Count = p.combine(merged[n-1].Count, b.Count)
+			continue
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+// Merge combines profiles, for example from tests that ran on different
+// containers, into one profile. In "set" mode a block is covered if any
+// profile covers it; in "count" and "atomic" mode the counts are added. Count
+// and atomic profiles can be merged with each other, but not with set
+// profiles.
+func Merge(profiles ...*Profile) (*Profile, error) {
+	if len(profiles) == 0 {
+		return nil, errors.New("coverage: no profiles to merge")
+	}
+	out := &Profile{Mode: profiles[0].Mode, Files: make(map[string][]Block)}
+	for _, p := range profiles {
+		if (p.Mode == "set") != (out.Mode == "set") {
+			return nil, fmt.Errorf("coverage: can't merge %q profile with %q profile", p.Mode, out.Mode)
+		}
+		for file, blocks := range p.Files {
+			out.Files[file] = append(out.Files[file], blocks...)
+		}
+	}
+	for file, blocks := range out.Files {
+		out.Files[file] = out.mergeBlocks(blocks)
+	}
+	return out, nil
+}
+
+// WriteTo writes p in the format "go tool cover" reads.
+func (p *Profile) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var total int64
+	n, _ := fmt.Fprintf(bw, "mode: %s\n", p.Mode)
+	total += int64(n)
+	files := make([]string, 0, len(p.Files))
+	for file := range p.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		for _, b := range p.Files[file] {
+			n, _ := fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", file, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count)
+			total += int64(n)
+		}
+	}
+	return total, bw.Flush()
+}
+
+// PackageCoverage is the number of statements in a package, and how many of
+// them were covered.
+type PackageCoverage struct {
+	Package    string
+	Statements int
+	Covered    int
+}
+
+// Percent returns the percentage of statements that were covered.
+func (c PackageCoverage) Percent() float64 {
+	if c.Statements == 0 {
+		return 0
+	}
+	return 100 * float64(c.Covered) / float64(c.Statements)
+}
+
+// Packages returns the coverage for each package in p, sorted by package
+// name.
+func (p *Profile) Packages() []PackageCoverage {
+	byPkg := make(map[string]*PackageCoverage)
+	for file, blocks := range p.Files {
+		pkg := path.Dir(file)
+		c, ok := byPkg[pkg]
+		if !ok {
+			c = &PackageCoverage{Package: pkg}
+			byPkg[pkg] = c
+		}
+		for _, b := range blocks {
+			c.Statements += b.NumStmt
+			if b.Count > 0 {
+				c.Covered += b.NumStmt
+			}
+		}
+	}
+	pkgs := make([]PackageCoverage, 0, len(byPkg))
+	for _, c := range byPkg {
+		pkgs = append(pkgs, *c)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Package < pkgs[j].Package })
+	return pkgs
+}
+
+// Total returns the coverage across every file in p.
+func (p *Profile) Total() PackageCoverage {
+	var total PackageCoverage
+	for _, c := range p.Packages() {
+		total.Statements += c.Statements
+		total.Covered += c.Covered
+	}
+	return total
+}
@@ -0,0 +1,88 @@
+package coverage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const node0 = `mode: count
+github.com/kevinburke/go-circle/circle.go:10.2,12.16 2 1
+github.com/kevinburke/go-circle/circle.go:14.2,14.10 1 0
+github.com/kevinburke/go-circle/wait/wait.go:5.1,6.2 4 0
+`
+
+const node1 = `mode: atomic
+github.com/kevinburke/go-circle/circle.go:14.2,14.10 1 3
+github.com/kevinburke/go-circle/circle.go:10.2,12.16 2 2
+github.com/kevinburke/go-circle/wait/wait.go:5.1,6.2 4 0
+`
+
+func mustParse(t *testing.T, s string) *Profile {
+	t.Helper()
+	p, err := Parse(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestMergeCount(t *testing.T) {
+	p, err := Merge(mustParse(t, node0), mustParse(t, node1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `mode: count
+github.com/kevinburke/go-circle/circle.go:10.2,12.16 2 3
+github.com/kevinburke/go-circle/circle.go:14.2,14.10 1 3
+github.com/kevinburke/go-circle/wait/wait.go:5.1,6.2 4 0
+`
+	if buf.String() != want {
+		t.Errorf("merged profile:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	pkgs := p.Packages()
+	if len(pkgs) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(pkgs))
+	}
+	if pkgs[0].Package != "github.com/kevinburke/go-circle" || pkgs[0].Percent() != 100 {
+		t.Errorf("unexpected coverage for %s: %.1f%%", pkgs[0].Package, pkgs[0].Percent())
+	}
+	if pkgs[1].Percent() != 0 {
+		t.Errorf("expected wait to have no coverage, got %.1f%%", pkgs[1].Percent())
+	}
+	if total := p.Total(); total.Statements != 7 || total.Covered != 3 {
+		t.Errorf("expected 3/7 statements covered, got %d/%d", total.Covered, total.Statements)
+	}
+}
+
+func TestMergeSet(t *testing.T) {
+	a := mustParse(t, "mode: set\na.go:1.1,2.2 1 1\na.go:3.1,4.2 1 0\n")
+	b := mustParse(t, "mode: set\na.go:1.1,2.2 1 1\na.go:3.1,4.2 1 0\n")
+	p, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Files["a.go"]; len(got) != 2 || got[0].Count != 1 || got[1].Count != 0 {
+		t.Errorf("unexpected merged blocks: %+v", got)
+	}
+	if _, err := Merge(a, mustParse(t, node0)); err == nil {
+		t.Errorf("expected error merging set and count profiles")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse(strings.NewReader("<html></html>\n")); err != ErrNotProfile {
+		t.Errorf("expected ErrNotProfile, got %v", err)
+	}
+	if _, err := Parse(strings.NewReader("")); err != ErrNotProfile {
+		t.Errorf("expected ErrNotProfile for empty input, got %v", err)
+	}
+	_, err := Parse(strings.NewReader("mode: set\na.go:1.1,2.2 1\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package circle
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvVar is a project environment variable. CircleCI never returns the full
+// value of a variable; values from the API are masked, like "xxxx1234".
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type envVarPage struct {
+	Items         []*EnvVar `json:"items"`
+	NextPageToken string    `json:"next_page_token"`
+}
+
+// ListEnvVars returns the environment variables for a project, with masked
+// values.
+func ListEnvVars(ctx context.Context, host, org, project string) ([]*EnvVar, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	if p.slug.hasV11() {
+		vars := make([]*EnvVar, 0)
+		if err := p.request(ctx, "GET", "/envvar", nil, &vars); err != nil {
+			return nil, err
+		}
+		return vars, nil
+	}
+	vars := make([]*EnvVar, 0)
+	token := ""
+	for {
+		uri := "/envvar"
+		if token != "" {
+			uri += "?page-token=" + url.QueryEscape(token)
+		}
+		page := new(envVarPage)
+		if err := p.request(ctx, "GET", uri, nil, page); err != nil {
+			return nil, err
+		}
+		vars = append(vars, page.Items...)
+		if page.NextPageToken == "" {
+			return vars, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// GetEnvVar returns the environment variable with the given name, with a
+// masked value.
+func GetEnvVar(ctx context.Context, host, org, project, name string) (*EnvVar, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	ev := new(EnvVar)
+	if err := p.request(ctx, "GET", "/envvar/"+url.PathEscape(name), nil, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// SetEnvVar creates the environment variable, or replaces its value if it
+// already exists. The returned EnvVar has a masked value.
+func SetEnvVar(ctx context.Context, host, org, project, name, value string) (*EnvVar, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	ev := new(EnvVar)
+	if err := p.request(ctx, "POST", "/envvar", &EnvVar{Name: name, Value: value}, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// DeleteEnvVar deletes the environment variable with the given name.
+func DeleteEnvVar(ctx context.Context, host, org, project, name string) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	return p.request(ctx, "DELETE", "/envvar/"+url.PathEscape(name), nil, nil)
+}
+
+// MaskEnvValue masks value the same way the CircleCI API does, so it can be
+// compared with a value returned by the API.
+func MaskEnvValue(value string) string {
+	r := []rune(value)
+	if len(r) < 4 {
+		return "xxxx"
+	}
+	return "xxxx" + string(r[len(r)-4:])
+}
+
+type EnvVarAction string
+
+const (
+	EnvVarAdd       EnvVarAction = "add"
+	EnvVarUpdate    EnvVarAction = "update"
+	EnvVarUnchanged EnvVarAction = "unchanged"
+)
+
+// EnvVarChange describes what setting a variable would do.
+type EnvVarChange struct {
+	Name   string
+	Action EnvVarAction
+	// Old is the current masked value, or "" if the variable does not exist.
+	Old string
+	// New is the desired value, masked.
+	New string
+}
+
+// DiffEnvVars compares the variables currently set on a project with the
+// desired variables, and returns a change for each desired variable, sorted by
+// name. Since the API only returns masked values, a variable is reported as
+// unchanged if its masked value matches; a new value that ends in the same
+// four characters as the old one can't be detected.
+func DiffEnvVars(current []*EnvVar, desired []*EnvVar) []EnvVarChange {
+	existing := make(map[string]string, len(current))
+	for _, ev := range current {
+		existing[ev.Name] = ev.Value
+	}
+	changes := make([]EnvVarChange, 0, len(desired))
+	for _, ev := range desired {
+		c := EnvVarChange{Name: ev.Name, New: MaskEnvValue(ev.Value)}
+		old, ok := existing[ev.Name]
+		switch {
+		case !ok:
+			c.Action = EnvVarAdd
+		case old == c.New:
+			c.Action = EnvVarUnchanged
+			c.Old = old
+		default:
+			c.Action = EnvVarUpdate
+			c.Old = old
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+var envNameRx = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseDotenv reads variables from a dotenv file. Each line should look like
+// "NAME=value", optionally preceded by "export". Blank lines and lines
+// starting with "#" are ignored. Values may be wrapped in single quotes, which
+// are taken literally, or double quotes, which support \n, \t, \" and \\
+// escapes. Unquoted values end at a " #" comment. If a variable appears more
+// than once, the last value wins.
+func ParseDotenv(r io.Reader) ([]*EnvVar, error) {
+	vars := make([]*EnvVar, 0)
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(line[len("export "):])
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq == -1 {
+			return nil, fmt.Errorf("line %d: expected NAME=value, got %q", lineno, line)
+		}
+		name := strings.TrimSpace(line[:eq])
+		if !envNameRx.MatchString(name) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineno, name)
+		}
+		value, err := parseDotenvValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineno, err)
+		}
+		if i, ok := index[name]; ok {
+			vars[i].Value = value
+			continue
+		}
+		index[name] = len(vars)
+		vars = append(vars, &EnvVar{Name: name, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func parseDotenvValue(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	switch v[0] {
+	case '\'':
+		end := strings.IndexByte(v[1:], '\'')
+		if end == -1 {
+			return "", fmt.Errorf("unterminated single quoted value")
+		}
+		return v[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(v); i++ {
+			c := v[i]
+			switch {
+			case c == '"':
+				return b.String(), nil
+			case c == '\\' && i+1 < len(v):
+				i++
+				switch v[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case 'r':
+					b.WriteByte('\r')
+				default:
+					b.WriteByte(v[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quoted value")
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v), nil
+}
@@ -0,0 +1,176 @@
+package circle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testConfig writes a config file that sends requests for the kevinburke
+// organization to apiBase and, if slug is not empty, uses slug for
+// github.com/kevinburke/go-circle. Call the returned function to restore the
+// old config.
+func testConfig(t *testing.T, apiBase, slug string) func() {
+	dir, err := ioutil.TempDir("", "go-circle-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf("[organizations]\n\n    [organizations.kevinburke]\n    token = \"tok\"\n    api_base = %q\n", apiBase)
+	if slug != "" {
+		cfg += fmt.Sprintf("\n[projects]\n\"github.com/kevinburke/go-circle\" = %q\n", slug)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "circleci"), []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	return func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+const dotenv = `
+# database settings
+DATABASE_URL=postgres://localhost/db
+export AWS_REGION = us-east-1
+QUOTED="line one\nline \"two\""
+LITERAL='$HOME\n'
+COMMENTED=value # trailing comment
+EMPTY=
+AWS_REGION=us-west-2
+`
+
+func TestParseDotenv(t *testing.T) {
+	vars, err := ParseDotenv(strings.NewReader(dotenv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EnvVar{
+		{"DATABASE_URL", "postgres://localhost/db"},
+		{"AWS_REGION", "us-west-2"},
+		{"QUOTED", "line one\nline \"two\""},
+		{"LITERAL", `$HOME\n`},
+		{"COMMENTED", "value"},
+		{"EMPTY", ""},
+	}
+	if len(vars) != len(want) {
+		t.Fatalf("expected %d vars, got %d", len(want), len(vars))
+	}
+	for i := range want {
+		if *vars[i] != want[i] {
+			t.Errorf("var %d: got %#v, want %#v", i, *vars[i], want[i])
+		}
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []string{
+		"NO_EQUALS",
+		"1BAD=name",
+		`OPEN="unterminated`,
+		"OPEN='unterminated",
+	}
+	for _, in := range tests {
+		if _, err := ParseDotenv(strings.NewReader(in)); err == nil {
+			t.Errorf("ParseDotenv(%q): expected error, got nil", in)
+		} else if !strings.HasPrefix(err.Error(), "line 1:") {
+			t.Errorf("ParseDotenv(%q): expected error to include line number, got %v", in, err)
+		}
+	}
+}
+
+func TestDiffEnvVars(t *testing.T) {
+	current := []*EnvVar{
+		{"SAME", "xxxx5678"},
+		{"CHANGED", "xxxx1111"},
+		{"UNTOUCHED", "xxxxabcd"},
+	}
+	desired := []*EnvVar{
+		{"SAME", "12345678"},
+		{"CHANGED", "22222222"},
+		{"NEW", "ab"},
+	}
+	changes := DiffEnvVars(current, desired)
+	want := []EnvVarChange{
+		{Name: "CHANGED", Action: EnvVarUpdate, Old: "xxxx1111", New: "xxxx2222"},
+		{Name: "NEW", Action: EnvVarAdd, New: "xxxx"},
+		{Name: "SAME", Action: EnvVarUnchanged, Old: "xxxx5678", New: "xxxx5678"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %d", len(want), len(changes))
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: got %#v, want %#v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestProjectRequestBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1.1/project/github/kevinburke/go-circle/envvar" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("expected JSON content type, got %q", ct)
+		}
+		ev := new(EnvVar)
+		if err := json.NewDecoder(r.Body).Decode(ev); err != nil {
+			t.Fatal(err)
+		}
+		ev.Value = MaskEnvValue(ev.Value)
+		json.NewEncoder(w).Encode(ev)
+	}))
+	defer s.Close()
+	p := &project{
+		slug: ProjectSlug{VCSTypeGithub, "kevinburke", "go-circle"},
+		cfg:  organization{Token: "tok", APIBase: s.URL},
+	}
+	ev := new(EnvVar)
+	if err := p.request(context.Background(), "POST", "/envvar", &EnvVar{"FOO", "secret-value"}, ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Value != "xxxxalue" {
+		t.Errorf("expected masked value, got %q", ev.Value)
+	}
+}
+
+func TestListEnvVarsV2Pages(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/gitlab/platform/api/envvar" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		switch r.URL.Query().Get("page-token") {
+		case "":
+			w.Write([]byte(`{"items": [{"name": "FOO", "value": "xxxx1234"}], "next_page_token": "page2"}`))
+		case "page2":
+			w.Write([]byte(`{"items": [{"name": "BAR", "value": "xxxx5678"}], "next_page_token": null}`))
+		default:
+			t.Errorf("unexpected page token %q", r.URL.Query().Get("page-token"))
+		}
+	}))
+	defer s.Close()
+	defer testConfig(t, s.URL, "gitlab/platform/api")()
+	vars, err := ListEnvVars(context.Background(), "github.com", "kevinburke", "go-circle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 2 {
+		t.Fatalf("expected variables from both pages, got %d", len(vars))
+	}
+	if vars[0].Name != "FOO" || vars[1].Name != "BAR" {
+		t.Errorf("expected FOO and BAR, got %s and %s", vars[0].Name, vars[1].Name)
+	}
+}
@@ -0,0 +1,138 @@
+package circle
+
+import (
+	"sort"
+	"time"
+)
+
+// BuildTestResults are the test results from a single build.
+type BuildTestResults struct {
+	Build   TreeBuild
+	Results []*TestResult
+}
+
+// FlakyTest is a test that both passed and failed without a code change.
+type FlakyTest struct {
+	Name      string `json:"name"`
+	Classname string `json:"classname"`
+	// Job is the name of the workflow job the test ran in, if any. The same
+	// test in different jobs is tracked separately.
+	Job      string `json:"job,omitempty"`
+	Runs     int    `json:"runs"`
+	Failures int    `json:"failures"`
+	// Flakes is the number of failures that weren't caused by a code change,
+	// because the test also passed on the same revision, and FlakeRate is
+	// Flakes divided by Runs.
+	Flakes    int     `json:"flakes"`
+	FlakeRate float64 `json:"flake_rate"`
+	// Flips is the number of other failures where the test passed in the
+	// builds immediately before and after the failure, on different
+	// revisions. These may be flakes, or a change that broke the test and was
+	// fixed or reverted right away, so they aren't counted as flakes.
+	Flips int `json:"flips"`
+	// LastFlake is the time of the most recent flake, and LastFlakeBuild is the
+	// build it happened in.
+	LastFlake      time.Time `json:"last_flake"`
+	LastFlakeBuild int       `json:"last_flake_build"`
+	LastMessage    string    `json:"last_message,omitempty"`
+}
+
+// FullName returns the test's name, with the class name if there is one.
+func (f *FlakyTest) FullName() string {
+	return (&TestResult{Name: f.Name, Classname: f.Classname}).FullName()
+}
+
+type flakyKey struct {
+	job, classname, name string
+}
+
+type testRun struct {
+	build   TreeBuild
+	passed  bool
+	message string
+}
+
+// buildTime returns the time a build finished, or the closest thing to it.
+func buildTime(tb TreeBuild) time.Time {
+	switch {
+	case tb.StopTime.Valid:
+		return tb.StopTime.Time
+	case tb.StartTime.Valid:
+		return tb.StartTime.Time
+	default:
+		return tb.QueuedAt.Time
+	}
+}
+
+// FindFlakyTests returns the tests in builds that failed without a code
+// change, because they passed in another build of the same revision. It also
+// returns the tests that failed once in between two passing builds, with the
+// failures counted as Flips instead of Flakes. Tests are sorted by flake rate,
+// highest first, then by the time of the last flake and then by the number of
+// flips.
+func FindFlakyTests(builds []BuildTestResults) []*FlakyTest {
+	runs := make(map[flakyKey][]testRun)
+	for _, b := range builds {
+		for _, t := range b.Results {
+			if t.Result != "success" && !t.Failed() {
+				continue
+			}
+			key := flakyKey{b.Build.Workflows.JobName, t.Classname, t.Name}
+			runs[key] = append(runs[key], testRun{
+				build:   b.Build,
+				passed:  !t.Failed(),
+				message: t.Message,
+			})
+		}
+	}
+	flaky := make([]*FlakyTest, 0)
+	for key, rs := range runs {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].build.BuildNum < rs[j].build.BuildNum })
+		passedRevisions := make(map[string]bool)
+		for _, r := range rs {
+			if r.passed && r.build.VCSRevision != "" {
+				passedRevisions[r.build.VCSRevision] = true
+			}
+		}
+		f := &FlakyTest{Name: key.name, Classname: key.classname, Job: key.job, Runs: len(rs)}
+		for i, r := range rs {
+			if r.passed {
+				continue
+			}
+			f.Failures++
+			if !passedRevisions[r.build.VCSRevision] {
+				if i > 0 && i < len(rs)-1 && rs[i-1].passed && rs[i+1].passed {
+					f.Flips++
+				}
+				continue
+			}
+			f.Flakes++
+			if t := buildTime(r.build); f.Flakes == 1 || t.After(f.LastFlake) {
+				f.LastFlake = t
+				f.LastFlakeBuild = r.build.BuildNum
+				f.LastMessage = r.message
+			}
+		}
+		if f.Flakes == 0 && f.Flips == 0 {
+			continue
+		}
+		f.FlakeRate = float64(f.Flakes) / float64(f.Runs)
+		flaky = append(flaky, f)
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].FlakeRate != flaky[j].FlakeRate {
+			return flaky[i].FlakeRate > flaky[j].FlakeRate
+		}
+		if !flaky[i].LastFlake.Equal(flaky[j].LastFlake) {
+			return flaky[i].LastFlake.After(flaky[j].LastFlake)
+		}
+		if flaky[i].Flips != flaky[j].Flips {
+			return flaky[i].Flips > flaky[j].Flips
+		}
+		if flaky[i].FullName() != flaky[j].FullName() {
+			return flaky[i].FullName() < flaky[j].FullName()
+		}
+		return flaky[i].Job < flaky[j].Job
+	})
+	return flaky
+}
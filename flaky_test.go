@@ -0,0 +1,48 @@
+package circle
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+func flakyBuild(num int, rev string, results ...string) BuildTestResults {
+	b := BuildTestResults{Build: TreeBuild{
+		BuildNum:    num,
+		VCSRevision: rev,
+		StopTime:    types.NullTime{Valid: true, Time: time.Date(2018, 6, 1, num, 0, 0, 0, time.UTC)},
+	}}
+	for i := 0; i < len(results); i += 2 {
+		b.Results = append(b.Results, &TestResult{Name: results[i], Classname: "example.com/math", Result: results[i+1], Message: "boom"})
+	}
+	return b
+}
+
+func TestFindFlakyTests(t *testing.T) {
+	builds := []BuildTestResults{
+		// TestRetry failed on abc and passed when the build was retried.
+		flakyBuild(1, "abc", "TestRetry", "failure", "TestFlip", "success", "TestBroken", "success"),
+		flakyBuild(2, "abc", "TestRetry", "success", "TestFlip", "success", "TestBroken", "success"),
+		// TestFlip failed once in between passing builds on other
+		// revisions, which might have been a change that was reverted.
+		flakyBuild(3, "def", "TestRetry", "success", "TestFlip", "failure", "TestBroken", "failure"),
+		// TestBroken stayed broken until it was fixed.
+		flakyBuild(4, "ghi", "TestRetry", "success", "TestFlip", "success", "TestBroken", "failure"),
+		flakyBuild(5, "jkl", "TestRetry", "skipped", "TestFlip", "success", "TestBroken", "success"),
+	}
+	flaky := FindFlakyTests(builds)
+	if len(flaky) != 2 {
+		t.Fatalf("expected 2 flaky tests, got %d: %+v", len(flaky), flaky)
+	}
+	retry, flip := flaky[0], flaky[1]
+	if retry.Name != "TestRetry" || retry.Runs != 4 || retry.Flakes != 1 || retry.FlakeRate != 0.25 {
+		t.Errorf("unexpected retry result: %+v", retry)
+	}
+	if retry.LastFlakeBuild != 1 || retry.LastMessage != "boom" {
+		t.Errorf("expected last flake to be build 1, got %+v", retry)
+	}
+	if flip.Name != "TestFlip" || flip.Runs != 5 || flip.Flakes != 0 || flip.Flips != 1 || flip.FlakeRate != 0 {
+		t.Errorf("a failure between builds of other revisions should be a flip, not a flake: %+v", flip)
+	}
+}
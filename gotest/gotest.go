@@ -0,0 +1,332 @@
+// Package gotest finds the failing tests in the output of "go test", so we
+// can print the handful of lines that matter instead of the whole log.
+//
+// It understands plain and verbose (-v) output, the event stream written by
+// "go test -json", panics, race detector reports and build errors.
+package gotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Kind describes why a test or package failed.
+type Kind string
+
+const (
+	KindTest  Kind = "FAIL"
+	KindPanic Kind = "PANIC"
+	KindRace  Kind = "RACE"
+	KindBuild Kind = "BUILD FAILED"
+)
+
+// Failure is a failing test, or a package that failed without a failing test
+// to blame, for example because it didn't compile.
+type Failure struct {
+	Package string
+	// Test is empty if the failure belongs to the whole package.
+	Test string
+	Kind Kind
+	// Output is the log for the failure, one line per element.
+	Output []string
+}
+
+// Name returns a short name for the failure, like "TestFoo (example.com/pkg)".
+func (f Failure) Name() string {
+	switch {
+	case f.Test == "":
+		return f.Package
+	case f.Package == "":
+		return f.Test
+	default:
+		return fmt.Sprintf("%s (%s)", f.Test, f.Package)
+	}
+}
+
+// Write prints failures in a compact form to w.
+func Write(w io.Writer, failures []Failure) error {
+	for _, f := range failures {
+		if _, err := fmt.Fprintf(w, "--- %s: %s\n", f.Kind, f.Name()); err != nil {
+			return err
+		}
+		for _, line := range f.Output {
+			if !strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "\t") {
+				line = "    " + line
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteSummary writes the failures in text to w. If text doesn't contain
+// go test output, it writes text unchanged. WriteSummary reports whether it
+// left anything out.
+func WriteSummary(w io.Writer, text string) (bool, error) {
+	failures := Parse(text)
+	if len(failures) == 0 {
+		_, err := fmt.Fprintln(w, text)
+		return false, err
+	}
+	if err := Write(w, failures); err != nil {
+		return true, err
+	}
+	_, err := fmt.Fprintln(w)
+	return true, err
+}
+
+var (
+	runLine     = regexp.MustCompile(`^=== (RUN|CONT|PAUSE)\s+(\S+)`)
+	resultLine  = regexp.MustCompile(`^\s*--- (FAIL|PASS|SKIP): (\S+) \(`)
+	packageLine = regexp.MustCompile(`^(ok  |FAIL|\?   )\t(\S+)`)
+	buildHeader = regexp.MustCompile(`^# (\S+)`)
+	// compiler errors look like "./foo.go:12:3: undefined: bar".
+	compileError = regexp.MustCompile(`^\S+\.go:\d+(:\d+)?: `)
+)
+
+// kindFor returns KindPanic or KindRace if lines contain a panic or a race
+// report, and k otherwise.
+func kindFor(k Kind, lines []string) Kind {
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "panic: "), strings.HasPrefix(line, "fatal error: "):
+			return KindPanic
+		case strings.HasPrefix(line, "WARNING: DATA RACE"):
+			k = KindRace
+		}
+	}
+	return k
+}
+
+// removeParents drops failures for tests that only failed because one of
+// their subtests did.
+func removeParents(failures []Failure) []Failure {
+	out := failures[:0]
+	for i, f := range failures {
+		parent := false
+		for j, g := range failures {
+			if i != j && f.Test != "" && f.Package == g.Package && strings.HasPrefix(g.Test, f.Test+"/") {
+				parent = true
+				break
+			}
+		}
+		if !parent || f.Kind != KindTest {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Parse returns the failures in the output of go test. If text doesn't look
+// like go test output, Parse returns an empty slice.
+func Parse(text string) []Failure {
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		if ev, ok := parseEvent(line); ok && ev.Action != "" {
+			return removeParents(parseJSON(lines))
+		}
+	}
+	return removeParents(parseText(lines))
+}
+
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+func parseEvent(line string) (event, bool) {
+	var ev event
+	if err := json.Unmarshal([]byte(line), &ev); err != nil {
+		return ev, false
+	}
+	return ev, true
+}
+
+func parseJSON(lines []string) []Failure {
+	type key struct{ pkg, test string }
+	output := make(map[key][]string)
+	failedTests := make(map[string]bool)
+	var failures []Failure
+	for _, line := range lines {
+		ev, ok := parseEvent(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			out := strings.TrimRight(ev.Output, "\n")
+			if runLine.MatchString(out) || resultLine.MatchString(out) || packageLine.MatchString(out) {
+				continue
+			}
+			if strings.TrimSpace(out) == "PASS" || strings.TrimSpace(out) == "FAIL" {
+				continue
+			}
+			output[k] = append(output[k], out)
+		case "fail":
+			if ev.Test != "" {
+				failedTests[ev.Package] = true
+				failures = append(failures, Failure{
+					Package: ev.Package,
+					Test:    ev.Test,
+					Kind:    kindFor(KindTest, output[k]),
+					Output:  output[k],
+				})
+			} else if !failedTests[ev.Package] {
+				kind := KindBuild
+				if len(output[k]) > 0 {
+					kind = kindFor(KindBuild, output[k])
+				}
+				failures = append(failures, Failure{Package: ev.Package, Kind: kind, Output: output[k]})
+			}
+			delete(output, k)
+		case "pass", "skip":
+			delete(output, k)
+		}
+	}
+	return failures
+}
+
+func parseText(lines []string) []Failure {
+	var failures []Failure
+	// pkgStart is the index in failures of the first failure in the current
+	// package; they don't know their package until we see its result line.
+	pkgStart := 0
+	// current is the test "go test -v" last said was running.
+	current := ""
+	pending := make(map[string][]string)
+	var pkgLines []string
+	// collecting is the failure that indented lines belong to, or -1.
+	collecting := -1
+	// inPanic is true while we're reading a panic's stack trace, which
+	// continues until the package result.
+	inPanic := false
+	inRace := false
+	building := -1
+
+	endPackage := func(pkg string, buildFailed bool) {
+		found := false
+		for i := pkgStart; i < len(failures); i++ {
+			if failures[i].Package == "" {
+				failures[i].Package = pkg
+			}
+			if failures[i].Package == pkg {
+				found = true
+			}
+		}
+		if !found && (buildFailed || kindFor(KindTest, pkgLines) != KindTest) {
+			kind := KindBuild
+			if !buildFailed {
+				kind = kindFor(KindTest, pkgLines)
+			}
+			failures = append(failures, Failure{Package: pkg, Kind: kind, Output: pkgLines})
+		}
+		pkgStart = len(failures)
+		current = ""
+		pending = make(map[string][]string)
+		pkgLines = nil
+		collecting, building = -1, -1
+		inPanic, inRace = false, false
+	}
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if m := packageLine.FindStringSubmatch(line); m != nil {
+			if m[1] == "FAIL" || strings.Contains(line, "[build failed]") || strings.Contains(line, "[setup failed]") {
+				endPackage(m[2], strings.Contains(line, "[build failed]") || strings.Contains(line, "[setup failed]"))
+			} else {
+				// a passing package, throw away anything we collected.
+				failures = failures[:pkgStart]
+				endPackage(m[2], false)
+			}
+			continue
+		}
+		if building >= 0 {
+			if compileError.MatchString(line) || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "note: ") {
+				failures[building].Output = append(failures[building].Output, line)
+				continue
+			}
+			building = -1
+		}
+		if m := buildHeader.FindStringSubmatch(line); m != nil {
+			failures = append(failures, Failure{Package: m[1], Kind: KindBuild})
+			building = len(failures) - 1
+			continue
+		}
+		if inPanic {
+			if strings.TrimSpace(line) == "FAIL" || strings.HasPrefix(line, "exit status ") {
+				continue
+			}
+			failures[collecting].Output = append(failures[collecting].Output, line)
+			continue
+		}
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			if collecting < 0 {
+				test := current
+				failures = append(failures, Failure{Test: test, Output: pending[test]})
+				collecting = len(failures) - 1
+			}
+			failures[collecting].Kind = KindPanic
+			failures[collecting].Output = append(failures[collecting].Output, line)
+			inPanic = true
+			continue
+		}
+		if m := runLine.FindStringSubmatch(line); m != nil {
+			current = m[2]
+			if m[1] == "RUN" {
+				pending[current] = nil
+			}
+			collecting = -1
+			continue
+		}
+		if m := resultLine.FindStringSubmatch(line); m != nil {
+			collecting = -1
+			if m[1] == "FAIL" {
+				failures = append(failures, Failure{Test: m[2], Kind: KindTest, Output: pending[m[2]]})
+				collecting = len(failures) - 1
+			}
+			delete(pending, m[2])
+			continue
+		}
+		if strings.HasPrefix(line, "WARNING: DATA RACE") {
+			inRace = true
+		}
+		switch {
+		case collecting >= 0 && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") || inRace):
+			failures[collecting].Output = append(failures[collecting].Output, line)
+		case current != "":
+			collecting = -1
+			pending[current] = append(pending[current], line)
+		default:
+			collecting = -1
+			if strings.TrimSpace(line) != "" && strings.TrimSpace(line) != "FAIL" {
+				pkgLines = append(pkgLines, line)
+			}
+		}
+		if inRace && strings.HasPrefix(line, "==================") {
+			// the report starts and ends with a line of "=". The first one
+			// comes before the WARNING line, so this is the end.
+			inRace = false
+		}
+	}
+	if len(failures) > pkgStart {
+		// output ended before the package result, probably because the
+		// step timed out.
+		endPackage("", false)
+	}
+	for i := range failures {
+		failures[i].Kind = kindFor(failures[i].Kind, failures[i].Output)
+	}
+	return failures
+}
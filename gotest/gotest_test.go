@@ -0,0 +1,146 @@
+package gotest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const verboseOutput = `go test -v ./...
+=== RUN   TestAdd
+--- PASS: TestAdd (0.00s)
+=== RUN   TestSub
+    math_test.go:12: lots of setup
+--- FAIL: TestSub (0.00s)
+    math_test.go:20: got 3, want 4
+=== RUN   TestTable
+=== RUN   TestTable/zero
+=== RUN   TestTable/negative
+--- FAIL: TestTable (0.00s)
+    --- PASS: TestTable/zero (0.00s)
+    --- FAIL: TestTable/negative (0.00s)
+        math_test.go:31: got -1, want 1
+FAIL
+FAIL	example.com/math	0.012s
+ok  	example.com/strings	0.004s
+`
+
+func TestParseVerbose(t *testing.T) {
+	failures := Parse(verboseOutput)
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d: %+v", len(failures), failures)
+	}
+	sub := failures[0]
+	if sub.Name() != "TestSub (example.com/math)" || sub.Kind != KindTest {
+		t.Errorf("unexpected first failure: %+v", sub)
+	}
+	if len(sub.Output) != 2 || !strings.Contains(sub.Output[1], "got 3, want 4") {
+		t.Errorf("unexpected output for TestSub: %q", sub.Output)
+	}
+	if failures[1].Test != "TestTable/negative" {
+		t.Errorf("expected the failing subtest, not its parent, got %q", failures[1].Test)
+	}
+}
+
+func TestParsePlain(t *testing.T) {
+	out := "--- FAIL: TestSub (0.00s)\n\tmath_test.go:20: got 3, want 4\nFAIL\nFAIL\texample.com/math\t0.012s\n"
+	failures := Parse(out)
+	if len(failures) != 1 || failures[0].Test != "TestSub" || failures[0].Package != "example.com/math" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+	if len(failures[0].Output) != 1 {
+		t.Errorf("expected one line of output, got %q", failures[0].Output)
+	}
+}
+
+func TestParsePanic(t *testing.T) {
+	out := `=== RUN   TestBoom
+--- FAIL: TestBoom (0.00s)
+panic: runtime error: index out of range [recovered]
+	panic: runtime error: index out of range
+
+goroutine 5 [running]:
+testing.tRunner.func1(0xc4200a2000)
+FAIL	example.com/boom	0.010s
+`
+	failures := Parse(out)
+	if len(failures) != 1 || failures[0].Kind != KindPanic || failures[0].Test != "TestBoom" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+	if !strings.HasPrefix(failures[0].Output[0], "panic: runtime error") {
+		t.Errorf("expected output to start with the panic, got %q", failures[0].Output[0])
+	}
+}
+
+func TestParseRace(t *testing.T) {
+	out := `=== RUN   TestRacy
+==================
+WARNING: DATA RACE
+Write at 0x00c4200a2000 by goroutine 7:
+  example.com/racy.TestRacy.func1()
+==================
+--- FAIL: TestRacy (0.00s)
+	testing.go:730: race detected during execution of test
+FAIL
+FAIL	example.com/racy	1.010s
+`
+	failures := Parse(out)
+	if len(failures) != 1 || failures[0].Kind != KindRace {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+}
+
+func TestParseBuildError(t *testing.T) {
+	out := `# example.com/broken
+./broken.go:5:2: undefined: fmt.Printlnx
+./broken.go:9:1: missing return at end of function
+FAIL	example.com/broken [build failed]
+ok  	example.com/fine	0.004s
+`
+	failures := Parse(out)
+	if len(failures) != 1 || failures[0].Kind != KindBuild || failures[0].Package != "example.com/broken" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+	if len(failures[0].Output) != 2 {
+		t.Errorf("expected 2 compiler errors, got %q", failures[0].Output)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	out := `{"Action":"run","Package":"example.com/math","Test":"TestSub"}
+{"Action":"output","Package":"example.com/math","Test":"TestSub","Output":"=== RUN   TestSub\n"}
+{"Action":"output","Package":"example.com/math","Test":"TestSub","Output":"--- FAIL: TestSub (0.00s)\n"}
+{"Action":"output","Package":"example.com/math","Test":"TestSub","Output":"    math_test.go:20: got 3, want 4\n"}
+{"Action":"fail","Package":"example.com/math","Test":"TestSub","Elapsed":0}
+{"Action":"run","Package":"example.com/math","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/math","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"pass","Package":"example.com/math","Test":"TestAdd","Elapsed":0}
+{"Action":"output","Package":"example.com/math","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/math","Elapsed":0.01}
+`
+	failures := Parse(out)
+	if len(failures) != 1 || failures[0].Name() != "TestSub (example.com/math)" {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+	if len(failures[0].Output) != 1 || !strings.Contains(failures[0].Output[0], "got 3, want 4") {
+		t.Errorf("unexpected output: %q", failures[0].Output)
+	}
+}
+
+func TestParseNotGoTest(t *testing.T) {
+	if failures := Parse("npm ERR! Test failed.  See above for more details.\n"); len(failures) != 0 {
+		t.Errorf("expected no failures, got %+v", failures)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, []Failure{{Package: "example.com/math", Test: "TestSub", Kind: KindTest, Output: []string{"math_test.go:20: got 3, want 4"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- FAIL: TestSub (example.com/math)\n    math_test.go:20: got 3, want 4\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
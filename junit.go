@@ -0,0 +1,118 @@
+package circle
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrNotJUnit is returned by ParseJUnit if the input isn't a JUnit XML report.
+var ErrNotJUnit = errors.New("circle: not a JUnit XML report")
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (m *junitMessage) String() string {
+	if text := strings.TrimSpace(m.Text); text != "" {
+		return text
+	}
+	return m.Message
+}
+
+// ParseJUnit reads the test cases from a JUnit XML report, like the ones
+// go-junit-report and most test runners write, in the same format as the
+// CircleCI tests endpoint. Test suites may be nested; a test case without a
+// class name gets the name of the suite it is in.
+func ParseJUnit(r io.Reader) ([]*TestResult, error) {
+	dec := xml.NewDecoder(r)
+	results := make([]*TestResult, 0)
+	sawRoot := false
+	var suites []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			if !sawRoot {
+				return nil, ErrNotJUnit
+			}
+			return results, nil
+		}
+		if err != nil {
+			if !sawRoot {
+				return nil, ErrNotJUnit
+			}
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !sawRoot && t.Name.Local != "testsuites" && t.Name.Local != "testsuite" {
+				return nil, ErrNotJUnit
+			}
+			sawRoot = true
+			switch t.Name.Local {
+			case "testsuite":
+				name := ""
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "name" {
+						name = attr.Value
+					}
+				}
+				suites = append(suites, name)
+			case "testcase":
+				c := new(junitCase)
+				if err := dec.DecodeElement(c, &t); err != nil {
+					return nil, err
+				}
+				suite := ""
+				if len(suites) > 0 {
+					suite = suites[len(suites)-1]
+				}
+				results = append(results, c.result(suite))
+			}
+		case xml.EndElement:
+			if t.Name.Local == "testsuite" && len(suites) > 0 {
+				suites = suites[:len(suites)-1]
+			}
+		}
+	}
+}
+
+func (c *junitCase) result(suite string) *TestResult {
+	t := &TestResult{
+		Name:      c.Name,
+		Classname: c.Classname,
+		File:      c.File,
+		Result:    "success",
+		Source:    "junit",
+	}
+	if t.Classname == "" {
+		t.Classname = suite
+	}
+	// some reporters format large times with a thousands separator.
+	t.RunTime, _ = strconv.ParseFloat(strings.Replace(c.Time, ",", "", -1), 64)
+	switch {
+	case c.Failure != nil:
+		t.Result = "failure"
+		t.Message = c.Failure.String()
+	case c.Error != nil:
+		t.Result = "error"
+		t.Message = c.Error.String()
+	case c.Skipped != nil:
+		t.Result = "skipped"
+		t.Message = c.Skipped.String()
+	}
+	return t
+}
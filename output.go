@@ -0,0 +1,179 @@
+package circle
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var errOutputV2 = errors.New("step output is only available for GitHub and Bitbucket projects")
+
+// GetStepOutput returns the console output for one container of a build
+// step. step is the number returned by CircleBuild.StepNumber, and node is the
+// container index. If the step is still running, GetStepOutput returns the
+// output so far.
+func GetStepOutput(ctx context.Context, host, org, project string, build, step, node int) (CircleOutputs, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	if !p.slug.hasV11() {
+		return nil, errOutputV2
+	}
+	var outputs CircleOutputs
+	if err := p.request(ctx, "GET", fmt.Sprintf("/%d/output/%d/%d", build, step, node), nil, &outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// String returns the messages in o, joined together.
+func (o CircleOutputs) String() string {
+	var b strings.Builder
+	for _, out := range o {
+		b.WriteString(out.Message)
+	}
+	return b.String()
+}
+
+// OutputReader reads the console output for an action one message at a time.
+// Messages are decoded as they arrive, so the whole log is never held in
+// memory unless you ask for it with Text. Callers should close the reader
+// when they are done.
+type OutputReader struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// newOutputReader reads a JSON array of CircleOutput from body, which may be
+// gzipped.
+func newOutputReader(body io.ReadCloser) (*OutputReader, error) {
+	br := bufio.NewReader(body)
+	var r io.Reader = br
+	// Output stored on S3 is sometimes gzipped without a Content-Encoding
+	// header, so net/http doesn't decompress it for us.
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		r = gz
+	}
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("reading output: %v", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		body.Close()
+		return nil, fmt.Errorf("reading output: expected an array, got %v", tok)
+	}
+	return &OutputReader{body: body, dec: dec}, nil
+}
+
+// Next returns the next message in the output, or io.EOF if there are no more
+// messages.
+func (r *OutputReader) Next() (*CircleOutput, error) {
+	if !r.dec.More() {
+		return nil, io.EOF
+	}
+	out := new(CircleOutput)
+	if err := r.dec.Decode(out); err != nil {
+		return nil, fmt.Errorf("reading output: %v", err)
+	}
+	return out, nil
+}
+
+// WriteTo writes the remaining messages to w, as they arrive.
+func (r *OutputReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		out, err := r.Next()
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+		n, err := io.WriteString(w, out.Message)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// Text reads the remaining messages into a string.
+func (r *OutputReader) Text() (string, error) {
+	var b strings.Builder
+	_, err := r.WriteTo(&b)
+	return b.String(), err
+}
+
+func (r *OutputReader) Close() error {
+	return r.body.Close()
+}
+
+// OpenOutput starts reading the console output for action, which belongs to
+// cb.Steps[step]. If CircleCI has stored the output, OpenOutput downloads it
+// from the action's presigned OutputURL; otherwise, for example while the step
+// is still running, it asks the API for the output so far.
+func (cb CircleBuild) OpenOutput(ctx context.Context, step int, action Action) (*OutputReader, error) {
+	o, err := getOrganization(cb.Username)
+	if err != nil {
+		return nil, err
+	}
+	return o.openOutput(ctx, cb, step, action)
+}
+
+func (o organization) openOutput(ctx context.Context, cb CircleBuild, step int, action Action) (*OutputReader, error) {
+	client, err := o.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	var req *http.Request
+	if action.OutputURL.URL != nil && action.OutputURL.String() != "" {
+		// The URL is presigned, so it doesn't need (and shouldn't get) our
+		// token.
+		req, err = http.NewRequest("GET", action.OutputURL.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !(ProjectSlug{VCS: VCS(cb.VCSType)}).hasV11() {
+			return nil, errOutputV2
+		}
+		u := fmt.Sprintf("%s/%s/%s/%s/%d/output/%d/%d", o.v11Base(), cb.VCSType, cb.Username, cb.RepoName, cb.BuildNum, cb.StepNumber(step, action), action.Index)
+		if action.AllocationID != "" {
+			// 2.0 builds need the allocation ID to find the right container.
+			u += "?allocation-id=" + url.QueryEscape(action.AllocationID)
+		}
+		req, err = http.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(o.Token, "")
+		req.Header.Set("Circle-Token", o.Token)
+		req.Header.Set("Accept", "application/json")
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("go-circle/%s", VERSION))
+	req = req.WithContext(ctx)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching output for %q: request failed with status [%d]", action.Name, resp.StatusCode)
+	}
+	return newOutputReader(resp.Body)
+}
@@ -0,0 +1,118 @@
+package circle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStepNumber(t *testing.T) {
+	steps := []Step{
+		{Name: "Spin up Environment", Actions: []Action{{Step: 0}}},
+		{Name: "Checkout code", Actions: []Action{{Step: 101}, {Step: 101, HasFailed: true}}},
+	}
+	v1 := CircleBuild{Platform: "1.0", Steps: steps}
+	if got := v1.StepNumber(1, steps[1].Actions[0]); got != 1 {
+		t.Errorf("1.0 build: expected step 1, got %d", got)
+	}
+	v2 := CircleBuild{Platform: "2.0", Steps: steps}
+	if got := v2.StepNumber(1, steps[1].Actions[0]); got != 101 {
+		t.Errorf("2.0 build: expected step 101, got %d", got)
+	}
+	failures := v2.Failures()
+	if len(failures) != 1 || failures[0] != [2]int{101, 1} {
+		t.Errorf("expected failure at step 101 node 1, got %v", failures)
+	}
+}
+
+func TestOutputsString(t *testing.T) {
+	o := CircleOutputs{{Message: "go test ./...\r\n"}, {Message: "ok  "}, {Message: "pkg\n"}}
+	if got := o.String(); got != "go test ./...\r\nok  pkg\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+const outputJSON = `[{"message": "=== RUN   TestFoo\r\n", "type": "out", "time": "2018-06-01T12:00:00Z"},
+{"message": "panic: boom\r\n", "type": "err", "time": "2018-06-01T12:00:01Z"}]`
+
+func TestOpenOutputPresigned(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Circle-Token") != "" || r.URL.Query().Get("circle-token") != "" {
+			t.Errorf("expected no token to be sent to the presigned URL")
+		}
+		// gzipped, but without a Content-Encoding header.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		gz := gzip.NewWriter(w)
+		io.WriteString(gz, outputJSON)
+		gz.Close()
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL + "/output/0.gz?Signature=abc")
+	o := organization{Token: "tok"}
+	cb := CircleBuild{Platform: "2.0", VCSType: "github", Username: "kevinburke", RepoName: "go-circle", BuildNum: 8}
+	r, err := o.openOutput(context.Background(), cb, 0, Action{Name: "go test", OutputURL: URL{u}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	first, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Type != "out" || first.Time.Second() != 0 {
+		t.Errorf("unexpected first message: %+v", first)
+	}
+	second, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Type != "err" || second.Message != "panic: boom\r\n" {
+		t.Errorf("unexpected second message: %+v", second)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestOpenOutputAPI(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1.1/project/github/kevinburke/go-circle/8/output/102/1"; r.URL.Path != want {
+			t.Errorf("expected path %s, got %s", want, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("allocation-id"); got != "abc-1" {
+			t.Errorf("expected allocation-id abc-1, got %q", got)
+		}
+		if user, _, _ := r.BasicAuth(); user != "tok" {
+			t.Errorf("expected token as basic auth user, got %q", user)
+		}
+		io.WriteString(w, outputJSON)
+	}))
+	defer s.Close()
+	o := organization{Token: "tok", APIBase: s.URL}
+	cb := CircleBuild{Platform: "2.0", VCSType: "github", Username: "kevinburke", RepoName: "go-circle", BuildNum: 8}
+	r, err := o.openOutput(context.Background(), cb, 3, Action{Step: 102, Index: 1, AllocationID: "abc-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "=== RUN   TestFoo\r\npanic: boom\r\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestOpenOutputV2(t *testing.T) {
+	o := organization{Token: "tok", APIBase: "http://127.0.0.1:1"}
+	cb := CircleBuild{VCSType: "gitlab", Username: "platform", RepoName: "api", BuildNum: 8}
+	if _, err := o.openOutput(context.Background(), cb, 0, Action{Step: 1}); err != errOutputV2 {
+		t.Errorf("expected errOutputV2, got %v", err)
+	}
+}
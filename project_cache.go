@@ -0,0 +1,25 @@
+package circle
+
+import (
+	"context"
+	"errors"
+)
+
+type clearCacheResponse struct {
+	Status string `json:"status"`
+}
+
+// ClearCache deletes the dependency caches for a project, so the next build
+// starts without restoring any cache. This clears CircleCI's remote cache, not
+// the local build cache in BuildCache.
+func ClearCache(ctx context.Context, host, org, project string) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	if !p.slug.hasV11() {
+		return errors.New("clearing the build cache is only available for GitHub and Bitbucket projects")
+	}
+	resp := new(clearCacheResponse)
+	return p.request(ctx, "DELETE", "/build-cache", nil, resp)
+}
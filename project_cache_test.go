@@ -0,0 +1,57 @@
+package circle
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClearCache(t *testing.T) {
+	s := newRecordingServer(t, map[string]string{
+		"DELETE " + v11Project + "/build-cache": `{"status": "build dependency caches deleted"}`,
+	})
+	defer s.Close()
+	defer testConfig(t, s.URL, "")()
+	if err := ClearCache(context.Background(), "github.com", "kevinburke", "go-circle"); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(s.requests))
+	}
+
+	defer testConfig(t, s.URL, "gitlab/platform/api")()
+	if err := ClearCache(context.Background(), "github.com", "kevinburke", "go-circle"); err == nil {
+		t.Error("expected an error clearing the cache of a v2 project")
+	}
+	if len(s.requests) != 1 {
+		t.Errorf("expected no request for a v2 project, got %d requests", len(s.requests))
+	}
+}
+
+func TestTriggerBuild(t *testing.T) {
+	s := newRecordingServer(t, map[string]string{
+		"POST " + v11Project + "/tree/feature%2Fcache":  `{}`,
+		"POST /v2/project/gitlab/platform/api/pipeline": `{}`,
+	})
+	defer s.Close()
+	ctx := context.Background()
+	defer testConfig(t, s.URL, "")()
+	if err := TriggerBuild(ctx, "github.com", "kevinburke", "go-circle", "feature/cache"); err != nil {
+		t.Fatal(err)
+	}
+	defer testConfig(t, s.URL, "gitlab/platform/api")()
+	if err := TriggerBuild(ctx, "github.com", "kevinburke", "go-circle", "feature/cache"); err != nil {
+		t.Fatal(err)
+	}
+	want := []recordedRequest{
+		{method: "POST", path: v11Project + "/tree/feature%2Fcache"},
+		{method: "POST", path: "/v2/project/gitlab/platform/api/pipeline", body: `{"branch":"feature/cache"}`},
+	}
+	if len(s.requests) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(s.requests))
+	}
+	for i := range want {
+		if s.requests[i] != want[i] {
+			t.Errorf("request %d: got %+v, want %+v", i, s.requests[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package circle
+
+import (
+	"sort"
+	"time"
+)
+
+// QueueTime returns how long the build waited to start, and false if the
+// build hasn't started or CircleCI didn't say when it was queued. The wait is
+// measured from usage_queued_at, when the build started waiting for a
+// container, if it is set.
+func (tb TreeBuild) QueueTime() (time.Duration, bool) {
+	if !tb.StartTime.Valid {
+		return 0, false
+	}
+	switch {
+	case tb.UsageQueuedAt.Valid:
+		return tb.StartTime.Time.Sub(tb.UsageQueuedAt.Time), true
+	case tb.QueuedAt.Valid:
+		return tb.StartTime.Time.Sub(tb.QueuedAt.Time), true
+	default:
+		return 0, false
+	}
+}
+
+// RunTime returns how long the build ran, and false if it hasn't finished.
+func (tb TreeBuild) RunTime() (time.Duration, bool) {
+	if !tb.StartTime.Valid || !tb.StopTime.Valid {
+		return 0, false
+	}
+	return tb.StopTime.Time.Sub(tb.StartTime.Time), true
+}
+
+// QueueStats summarizes how long builds waited for a container.
+type QueueStats struct {
+	// Builds is the number of builds with a known queue time.
+	Builds int
+	// Queued and Running are the total time builds spent waiting to start
+	// and running, for finished builds.
+	Queued, Running time.Duration
+	// Waits are the queue times of every build, and ByHour and ByWeekday are
+	// the queue times of the builds queued in each hour of the day and on
+	// each day of the week.
+	Waits     []time.Duration
+	ByHour    [24][]time.Duration
+	ByWeekday [7][]time.Duration
+}
+
+// QueuedShare returns the fraction of the finished builds' time that was
+// spent waiting to start, between 0 and 1.
+func (q *QueueStats) QueuedShare() float64 {
+	if q.Queued+q.Running <= 0 {
+		return 0
+	}
+	return float64(q.Queued) / float64(q.Queued+q.Running)
+}
+
+// NewQueueStats computes queue statistics for builds. Hours and weekdays are
+// in loc.
+func NewQueueStats(builds []TreeBuild, loc *time.Location) *QueueStats {
+	q := new(QueueStats)
+	for _, tb := range builds {
+		wait, ok := tb.QueueTime()
+		if !ok {
+			continue
+		}
+		if wait < 0 {
+			// clock skew between CircleCI's services.
+			wait = 0
+		}
+		q.Builds++
+		q.Waits = append(q.Waits, wait)
+		queued := tb.StartTime.Time.Add(-wait).In(loc)
+		q.ByHour[queued.Hour()] = append(q.ByHour[queued.Hour()], wait)
+		q.ByWeekday[queued.Weekday()] = append(q.ByWeekday[queued.Weekday()], wait)
+		if run, ok := tb.RunTime(); ok {
+			q.Queued += wait
+			q.Running += run
+		}
+	}
+	return q
+}
+
+// ConcurrencySample is the number of builds running during one period.
+type ConcurrencySample struct {
+	Start time.Time
+	// Max is the most builds running at once during the period, and Mean is
+	// the average number running.
+	Max  int
+	Mean float64
+}
+
+type concurrencyEvent struct {
+	at    time.Time
+	delta int
+}
+
+// Concurrency divides the time from start to end into periods of length
+// width, and returns the number of builds running during each one. Builds
+// that haven't finished are counted as running until end.
+func Concurrency(builds []TreeBuild, start, end time.Time, width time.Duration) []ConcurrencySample {
+	if width <= 0 || !end.After(start) {
+		return nil
+	}
+	var events []concurrencyEvent
+	for _, tb := range builds {
+		if !tb.StartTime.Valid {
+			continue
+		}
+		stop := end
+		if tb.StopTime.Valid {
+			stop = tb.StopTime.Time
+		}
+		if !stop.After(tb.StartTime.Time) {
+			continue
+		}
+		events = append(events, concurrencyEvent{tb.StartTime.Time, 1}, concurrencyEvent{stop, -1})
+	}
+	// at the same instant, process stops before starts, so back to back
+	// builds don't count as concurrent.
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].delta < events[j].delta
+	})
+	running := 0
+	i := 0
+	for ; i < len(events) && !events[i].at.After(start); i++ {
+		running += events[i].delta
+	}
+	var samples []ConcurrencySample
+	for t := start; t.Before(end); t = t.Add(width) {
+		next := t.Add(width)
+		if next.After(end) {
+			next = end
+		}
+		s := ConcurrencySample{Start: t, Max: running}
+		var area float64
+		last := t
+		for ; i < len(events) && events[i].at.Before(next); i++ {
+			area += float64(running) * float64(events[i].at.Sub(last))
+			last = events[i].at
+			running += events[i].delta
+			if running > s.Max {
+				s.Max = running
+			}
+		}
+		area += float64(running) * float64(next.Sub(last))
+		s.Mean = area / float64(next.Sub(t))
+		samples = append(samples, s)
+	}
+	return samples
+}
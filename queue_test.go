@@ -0,0 +1,70 @@
+package circle
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+func queueBuild(queued, started, stopped time.Time) TreeBuild {
+	tb := TreeBuild{
+		QueuedAt:  types.NullTime{Valid: true, Time: queued},
+		StartTime: types.NullTime{Valid: !started.IsZero(), Time: started},
+	}
+	if !stopped.IsZero() {
+		tb.StopTime = types.NullTime{Valid: true, Time: stopped}
+	}
+	return tb
+}
+
+func TestQueueStats(t *testing.T) {
+	// a Monday
+	day := time.Date(2018, 6, 4, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	builds := []TreeBuild{
+		queueBuild(at(9, 0), at(9, 1), at(9, 11)),
+		queueBuild(at(9, 30), at(9, 35), at(9, 40)),
+		queueBuild(at(14, 0), at(14, 0), time.Time{}),
+		queueBuild(at(15, 0), time.Time{}, time.Time{}),
+	}
+	q := NewQueueStats(builds, time.UTC)
+	if q.Builds != 3 {
+		t.Errorf("expected 3 builds with queue times, got %d", q.Builds)
+	}
+	if len(q.ByHour[9]) != 2 || q.ByHour[9][1] != 5*time.Minute || len(q.ByHour[14]) != 1 {
+		t.Errorf("unexpected hourly queue times: 9: %v, 14: %v", q.ByHour[9], q.ByHour[14])
+	}
+	if len(q.ByWeekday[time.Monday]) != 3 {
+		t.Errorf("expected 3 builds on Monday, got %v", q.ByWeekday[time.Monday])
+	}
+	if q.Queued != 6*time.Minute || q.Running != 15*time.Minute {
+		t.Errorf("expected 6m queued and 15m running, got %v and %v", q.Queued, q.Running)
+	}
+	if share := q.QueuedShare(); share < 0.285 || share > 0.286 {
+		t.Errorf("expected queued share 6/21, got %v", share)
+	}
+
+	samples := Concurrency(builds, at(9, 0), at(10, 0), 30*time.Minute)
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+	if samples[0].Max != 1 || samples[0].Mean < 0.333 || samples[0].Mean > 0.334 {
+		t.Errorf("unexpected first sample: %+v", samples[0])
+	}
+	if samples[1].Max != 1 || samples[1].Mean < 0.166 || samples[1].Mean > 0.167 {
+		t.Errorf("unexpected second sample: %+v", samples[1])
+	}
+	overlap := []TreeBuild{
+		queueBuild(at(9, 0), at(9, 0), at(9, 20)),
+		queueBuild(at(9, 0), at(9, 10), at(9, 30)),
+		queueBuild(at(9, 0), at(9, 30), at(9, 40)),
+	}
+	samples = Concurrency(overlap, at(9, 0), at(9, 40), 40*time.Minute)
+	if samples[0].Max != 2 {
+		t.Errorf("expected max of 2 concurrent builds, got %d", samples[0].Max)
+	}
+	if samples[0].Mean != 1.25 {
+		t.Errorf("expected mean of 1.25 builds, got %v", samples[0].Mean)
+	}
+}
@@ -0,0 +1,186 @@
+// Package quickfix finds file locations, like "main.go:12:3: undefined: x",
+// in build output, and rewrites them so an editor can open them from a local
+// checkout. The output can be loaded with "vim -q" or Emacs' compilation mode.
+package quickfix
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kevinburke/go-circle/gotest"
+	git "github.com/kevinburke/go-git"
+)
+
+// Location is a position in a file, with the message that refers to it.
+type Location struct {
+	File    string
+	Line    int
+	Col     int // zero if unknown
+	Message string
+	// Package is the Go package the location was reported for, if known.
+	// Test failures print file names relative to the package directory.
+	Package string
+}
+
+// String formats l in the "file:line:col: message" form editors understand.
+func (l Location) String() string {
+	if l.Col > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", l.File, l.Line, l.Col, l.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", l.File, l.Line, l.Message)
+}
+
+// Locations match the compiler, go vet, golint, staticcheck and t.Errorf. A
+// line number must be followed by a colon, which skips the "file.go:12 +0x1f"
+// lines in stack traces.
+var locationLine = regexp.MustCompile(`^\s*([^\s:]+\.[A-Za-z0-9]+):(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// Find returns the locations in text, in the order they appear.
+func Find(text string) []Location {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	// go test prints test failures relative to the package directory, so
+	// find out which package each test failure line belongs to.
+	packages := make(map[string]string)
+	for _, f := range gotest.Parse(text) {
+		if f.Package == "" {
+			continue
+		}
+		for _, line := range f.Output {
+			packages[strings.TrimSpace(line)] = f.Package
+		}
+	}
+	var locs []Location
+	seen := make(map[Location]bool)
+	for _, line := range strings.Split(text, "\n") {
+		m := locationLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		l := Location{File: m[1], Message: m[4], Package: packages[strings.TrimSpace(line)]}
+		l.Line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			l.Col, _ = strconv.Atoi(m[3])
+		}
+		if seen[l] {
+			continue
+		}
+		seen[l] = true
+		locs = append(locs, l)
+	}
+	return locs
+}
+
+// DefaultCheckoutDirs are the directories CircleCI checks projects out to.
+var DefaultCheckoutDirs = []string{
+	"/home/circleci/project",
+	"/root/project",
+}
+
+// PathMapper rewrites paths from a CircleCI container so they are relative to
+// a local checkout.
+type PathMapper struct {
+	// Root is the root of the local checkout.
+	Root string
+	// ImportPath is the Go import path of the repository, like
+	// "github.com/kevinburke/go-circle". Paths under a GOPATH in the
+	// container, like /go/src/github.com/kevinburke/go-circle/circle.go, are
+	// mapped using it.
+	ImportPath string
+	// CheckoutDirs are the directories the project may be checked out to in
+	// the container.
+	CheckoutDirs []string
+}
+
+// NewPathMapper returns a PathMapper for the git repository that contains
+// the working directory, which has the given remote.
+func NewPathMapper(remote *git.RemoteURL) (*PathMapper, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	root, err := findRoot(wd)
+	if err != nil {
+		return nil, err
+	}
+	dirs := append([]string{}, DefaultCheckoutDirs...)
+	// 1.0 builds check the project out into the home directory.
+	dirs = append(dirs, "/home/ubuntu/"+remote.RepoName)
+	return &PathMapper{
+		Root:         root,
+		ImportPath:   path.Join(remote.Host, remote.Path, remote.RepoName),
+		CheckoutDirs: dirs,
+	}, nil
+}
+
+// findRoot returns the root of the git repository containing dir.
+func findRoot(dir string) (string, error) {
+	for {
+		root, err := git.Root(dir)
+		if err == nil {
+			return root, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("quickfix: %s is not in a git repository", dir)
+		}
+		dir = parent
+	}
+}
+
+// Map returns file, which was reported for the Go package pkg (or "" if the
+// package isn't known), relative to m.Root. If file can't be mapped it is
+// returned unchanged.
+func (m *PathMapper) Map(file, pkg string) string {
+	var candidates []string
+	if path.IsAbs(file) {
+		if m.ImportPath != "" {
+			if i := strings.Index(file, "/"+m.ImportPath+"/"); i >= 0 {
+				candidates = append(candidates, file[i+len(m.ImportPath)+2:])
+			}
+		}
+		for _, dir := range m.CheckoutDirs {
+			if strings.HasPrefix(file, dir+"/") {
+				candidates = append(candidates, file[len(dir)+1:])
+			}
+		}
+	} else {
+		file = path.Clean(file)
+		if pkg != "" && !strings.Contains(file, "/") {
+			if pkg == m.ImportPath {
+				candidates = append(candidates, file)
+			} else if strings.HasPrefix(pkg, m.ImportPath+"/") {
+				candidates = append(candidates, path.Join(pkg[len(m.ImportPath)+1:], file))
+			}
+		}
+		candidates = append(candidates, file)
+	}
+	if len(candidates) == 0 {
+		return file
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(filepath.Join(m.Root, filepath.FromSlash(c))); err == nil {
+			return c
+		}
+	}
+	return candidates[0]
+}
+
+// Write maps each location in locs with m, and writes them to w, one per
+// line. If m is nil, locations are written unchanged.
+func Write(w io.Writer, locs []Location, m *PathMapper) error {
+	for _, l := range locs {
+		if m != nil {
+			l.File = m.Map(l.File, l.Package)
+		}
+		if _, err := fmt.Fprintln(w, l.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package quickfix
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const buildOutput = `# github.com/kevinburke/go-circle/wait
+wait/wait.go:12:3: undefined: foo
+/home/circleci/project/circle.go:40:2: should omit type int from declaration (golint)
+/go/src/github.com/kevinburke/go-circle/slug.go:9:1: exported func Bar should have comment (ST1000)
+FAIL	github.com/kevinburke/go-circle/wait [build failed]
+--- FAIL: TestSub (0.00s)
+    envvar_test.go:20: got 3, want 4
+panic: boom [recovered]
+	/usr/local/go/src/testing/testing.go:742 +0x29d
+FAIL
+FAIL	github.com/kevinburke/go-circle	0.012s
+`
+
+func TestFind(t *testing.T) {
+	locs := Find(buildOutput)
+	if len(locs) != 4 {
+		t.Fatalf("expected 4 locations, got %d: %+v", len(locs), locs)
+	}
+	if locs[0].File != "wait/wait.go" || locs[0].Line != 12 || locs[0].Col != 3 || locs[0].Message != "undefined: foo" {
+		t.Errorf("unexpected compiler location: %+v", locs[0])
+	}
+	test := locs[3]
+	if test.File != "envvar_test.go" || test.Col != 0 || test.Package != "github.com/kevinburke/go-circle" {
+		t.Errorf("unexpected test location: %+v", test)
+	}
+	if want := "envvar_test.go:20: got 3, want 4"; test.String() != want {
+		t.Errorf("String(): got %q, want %q", test.String(), want)
+	}
+}
+
+func TestMap(t *testing.T) {
+	root, err := ioutil.TempDir("", "quickfix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.MkdirAll(filepath.Join(root, "wait"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "wait", "wait_test.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &PathMapper{
+		Root:         root,
+		ImportPath:   "github.com/kevinburke/go-circle",
+		CheckoutDirs: DefaultCheckoutDirs,
+	}
+	tests := []struct {
+		file, pkg, want string
+	}{
+		{"/home/circleci/project/circle.go", "", "circle.go"},
+		{"/go/src/github.com/kevinburke/go-circle/wait/wait.go", "", "wait/wait.go"},
+		{"/home/circleci/.go_workspace/src/github.com/kevinburke/go-circle/slug.go", "", "slug.go"},
+		{"wait_test.go", "github.com/kevinburke/go-circle/wait", "wait/wait_test.go"},
+		{"envvar_test.go", "github.com/kevinburke/go-circle", "envvar_test.go"},
+		{"./wait/wait.go", "", "wait/wait.go"},
+		{"/usr/local/go/src/testing/testing.go", "", "/usr/local/go/src/testing/testing.go"},
+	}
+	for _, tt := range tests {
+		if got := m.Map(tt.file, tt.pkg); got != tt.want {
+			t.Errorf("Map(%q, %q): got %q, want %q", tt.file, tt.pkg, got, tt.want)
+		}
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, []Location{{File: "/home/circleci/project/circle.go", Line: 1, Col: 2, Message: "oops"}}, m); err != nil {
+		t.Fatal(err)
+	}
+	if want := "circle.go:1:2: oops\n"; buf.String() != want {
+		t.Errorf("Write: got %q, want %q", buf.String(), want)
+	}
+}
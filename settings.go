@@ -0,0 +1,87 @@
+package circle
+
+import "context"
+
+// ProjectSettings are the advanced settings for a project. When updating
+// settings, nil fields are left alone.
+type ProjectSettings struct {
+	// Build pull requests from forks of the repository.
+	BuildForkPRs *bool
+	// Cancel running builds on a branch when a newer commit is pushed.
+	AutoCancelBuilds *bool
+	// Only build branches that have an open pull request, and the default
+	// branch.
+	BuildPRsOnly *bool
+}
+
+// The v1.1 API calls these "feature flags"; the v2 API calls them "advanced"
+// settings and uses different names.
+type featureFlags struct {
+	BuildForkPRs     *bool `json:"build-fork-prs,omitempty"`
+	AutoCancelBuilds *bool `json:"autocancel-builds,omitempty"`
+	BuildPRsOnly     *bool `json:"build-prs-only,omitempty"`
+}
+
+type v11Settings struct {
+	FeatureFlags featureFlags `json:"feature_flags"`
+}
+
+type advancedSettings struct {
+	BuildForkPRs     *bool `json:"build_fork_prs,omitempty"`
+	AutoCancelBuilds *bool `json:"autocancel_builds,omitempty"`
+	BuildPRsOnly     *bool `json:"build_prs_only,omitempty"`
+}
+
+type v2Settings struct {
+	Advanced advancedSettings `json:"advanced"`
+}
+
+// GetProjectSettings returns the advanced settings for a project.
+func GetProjectSettings(ctx context.Context, host, org, project string) (*ProjectSettings, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	if p.slug.hasV11() {
+		s := new(v11Settings)
+		if err := p.request(ctx, "GET", "/settings", nil, s); err != nil {
+			return nil, err
+		}
+		return &ProjectSettings{
+			BuildForkPRs:     s.FeatureFlags.BuildForkPRs,
+			AutoCancelBuilds: s.FeatureFlags.AutoCancelBuilds,
+			BuildPRsOnly:     s.FeatureFlags.BuildPRsOnly,
+		}, nil
+	}
+	s := new(v2Settings)
+	if err := p.request(ctx, "GET", "/settings", nil, s); err != nil {
+		return nil, err
+	}
+	return &ProjectSettings{
+		BuildForkPRs:     s.Advanced.BuildForkPRs,
+		AutoCancelBuilds: s.Advanced.AutoCancelBuilds,
+		BuildPRsOnly:     s.Advanced.BuildPRsOnly,
+	}, nil
+}
+
+// UpdateProjectSettings changes the non-nil settings in s.
+func UpdateProjectSettings(ctx context.Context, host, org, project string, s *ProjectSettings) error {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return err
+	}
+	if p.slug.hasV11() {
+		body := &v11Settings{FeatureFlags: featureFlags{
+			BuildForkPRs:     s.BuildForkPRs,
+			AutoCancelBuilds: s.AutoCancelBuilds,
+			BuildPRsOnly:     s.BuildPRsOnly,
+		}}
+		return p.request(ctx, "PUT", "/settings", body, nil)
+	}
+	body := &v2Settings{Advanced: advancedSettings{
+		BuildForkPRs:     s.BuildForkPRs,
+		AutoCancelBuilds: s.AutoCancelBuilds,
+		BuildPRsOnly:     s.BuildPRsOnly,
+	}}
+	return p.request(ctx, "PATCH", "/settings", body, nil)
+}
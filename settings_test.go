@@ -0,0 +1,28 @@
+package circle
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSettingsOmitNil(t *testing.T) {
+	yes := true
+	body := &v11Settings{FeatureFlags: featureFlags{AutoCancelBuilds: &yes}}
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"feature_flags":{"autocancel-builds":true}}`; string(data) != want {
+		t.Errorf("expected %s, got %s", want, data)
+	}
+	s := new(v2Settings)
+	if err := json.Unmarshal([]byte(`{"advanced": {"build_fork_prs": false, "build_prs_only": true}}`), s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Advanced.BuildForkPRs == nil || *s.Advanced.BuildForkPRs {
+		t.Errorf("expected build_fork_prs to be false, got %v", s.Advanced.BuildForkPRs)
+	}
+	if s.Advanced.AutoCancelBuilds != nil {
+		t.Errorf("expected autocancel_builds to be unset")
+	}
+}
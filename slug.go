@@ -0,0 +1,111 @@
+package circle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProjectSlug identifies a project in the CircleCI API, for example
+// "gh/kevinburke/go-circle".
+//
+// Projects that are not tied to a Git organization (GitLab projects, or
+// projects using the GitHub App) use "circleci" as the VCS type. For those
+// projects Org is the organization ID and Project is the project ID.
+type ProjectSlug struct {
+	VCS     VCS
+	Org     string
+	Project string
+}
+
+var slugAbbreviations = map[string]VCS{
+	"gh":        VCSTypeGithub,
+	"github":    VCSTypeGithub,
+	"bb":        VCSTypeBitbucket,
+	"bitbucket": VCSTypeBitbucket,
+	"gl":        VCSTypeGitlab,
+	"gitlab":    VCSTypeGitlab,
+	"circleci":  VCSTypeCircleCI,
+}
+
+// ParseProjectSlug parses a project slug in any of the forms accepted by the
+// CircleCI API: "gh/org/repo", "github/org/repo", "bb/org/repo",
+// "bitbucket/org/repo", "gitlab/group/repo" or
+// "circleci/<org-id>/<project-id>". Slashes may be URL-escaped. GitLab slugs
+// may contain nested groups, like "gitlab/group/subgroup/repo".
+func ParseProjectSlug(s string) (ProjectSlug, error) {
+	s = strings.Replace(s, "%2F", "/", -1)
+	s = strings.Replace(s, "%2f", "/", -1)
+	parts := strings.Split(strings.Trim(s, "/"), "/")
+	if len(parts) < 3 {
+		return ProjectSlug{}, fmt.Errorf("invalid project slug %q: should look like gh/org/repo", s)
+	}
+	vcs, ok := slugAbbreviations[strings.ToLower(parts[0])]
+	if !ok {
+		return ProjectSlug{}, fmt.Errorf("invalid project slug %q: unknown VCS type %q", s, parts[0])
+	}
+	if len(parts) > 3 && vcs != VCSTypeGitlab {
+		return ProjectSlug{}, fmt.Errorf("invalid project slug %q: too many path segments", s)
+	}
+	for _, part := range parts[1:] {
+		if part == "" {
+			return ProjectSlug{}, fmt.Errorf("invalid project slug %q: empty path segment", s)
+		}
+	}
+	return ProjectSlug{
+		VCS:     vcs,
+		Org:     strings.Join(parts[1:len(parts)-1], "/"),
+		Project: parts[len(parts)-1],
+	}, nil
+}
+
+// String returns the slug in the short form used by the v2 API, for example
+// "gh/kevinburke/go-circle".
+func (s ProjectSlug) String() string {
+	var prefix string
+	switch s.VCS {
+	case VCSTypeGithub:
+		prefix = "gh"
+	case VCSTypeBitbucket:
+		prefix = "bb"
+	default:
+		prefix = string(s.VCS)
+	}
+	return prefix + "/" + s.Org + "/" + s.Project
+}
+
+// V1Path returns the slug in the long form used by the v1.1 API, for example
+// "github/kevinburke/go-circle".
+func (s ProjectSlug) V1Path() string {
+	return string(s.VCS) + "/" + s.Org + "/" + s.Project
+}
+
+// hasV11 reports whether the v1.1 API can be used for the project. GitLab and
+// "circleci" projects are only available through the v2 API.
+func (s ProjectSlug) hasV11() bool {
+	return s.VCS == VCSTypeGithub || s.VCS == VCSTypeBitbucket
+}
+
+// GetProjectSlug returns the slug for the project hosted at the given Git
+// host, organization and repository name. If the config file has an entry for
+// the project in the [projects] table, that slug is used instead.
+func GetProjectSlug(host, org, project string) (ProjectSlug, error) {
+	c, err := getConfig()
+	if err != nil {
+		return ProjectSlug{}, err
+	}
+	return c.projectSlug(host, org, project)
+}
+
+func (c *CircleConfig) projectSlug(host, org, project string) (ProjectSlug, error) {
+	key := host + "/" + org + "/" + project
+	for k, slug := range c.Projects {
+		if strings.EqualFold(k, key) {
+			return ParseProjectSlug(slug)
+		}
+	}
+	vcs, err := vcsType(host, c.VCSHosts)
+	if err != nil {
+		return ProjectSlug{}, err
+	}
+	return ProjectSlug{VCS: vcs, Org: org, Project: project}, nil
+}
@@ -0,0 +1,162 @@
+package circle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var slugTests = []struct {
+	in      string
+	want    ProjectSlug
+	str     string
+	v1      string
+	wantErr bool
+}{
+	{in: "gh/kevinburke/go-circle", want: ProjectSlug{VCSTypeGithub, "kevinburke", "go-circle"}, str: "gh/kevinburke/go-circle", v1: "github/kevinburke/go-circle"},
+	{in: "github/kevinburke/go-circle", want: ProjectSlug{VCSTypeGithub, "kevinburke", "go-circle"}, str: "gh/kevinburke/go-circle", v1: "github/kevinburke/go-circle"},
+	{in: "bitbucket/shyp/api", want: ProjectSlug{VCSTypeBitbucket, "shyp", "api"}, str: "bb/shyp/api", v1: "bitbucket/shyp/api"},
+	{in: "gitlab/group/sub/repo", want: ProjectSlug{VCSTypeGitlab, "group/sub", "repo"}, str: "gitlab/group/sub/repo", v1: "gitlab/group/sub/repo"},
+	{in: "circleci%2F1a2b%2F3c4d", want: ProjectSlug{VCSTypeCircleCI, "1a2b", "3c4d"}, str: "circleci/1a2b/3c4d", v1: "circleci/1a2b/3c4d"},
+	{in: "gh/kevinburke", wantErr: true},
+	{in: "svn/kevinburke/go-circle", wantErr: true},
+	{in: "gh/a/b/c", wantErr: true},
+	{in: "gh//go-circle", wantErr: true},
+}
+
+func TestParseProjectSlug(t *testing.T) {
+	for _, tt := range slugTests {
+		slug, err := ParseProjectSlug(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseProjectSlug(%q): expected error, got %v", tt.in, slug)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseProjectSlug(%q): %v", tt.in, err)
+			continue
+		}
+		if slug != tt.want {
+			t.Errorf("ParseProjectSlug(%q): got %#v, want %#v", tt.in, slug, tt.want)
+		}
+		if s := slug.String(); s != tt.str {
+			t.Errorf("ParseProjectSlug(%q).String(): got %q, want %q", tt.in, s, tt.str)
+		}
+		if s := slug.V1Path(); s != tt.v1 {
+			t.Errorf("ParseProjectSlug(%q).V1Path(): got %q, want %q", tt.in, s, tt.v1)
+		}
+	}
+}
+
+func TestConfigProjectSlug(t *testing.T) {
+	c := &CircleConfig{
+		Projects: map[string]string{
+			"gitlab.com/Platform/api": "circleci/org-id/project-id",
+		},
+	}
+	slug, err := c.projectSlug("gitlab.com", "platform", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ProjectSlug{VCSTypeCircleCI, "org-id", "project-id"}); slug != want {
+		t.Errorf("got %#v, want %#v", slug, want)
+	}
+	slug, err = c.projectSlug("gitlab.com", "platform", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ProjectSlug{VCSTypeGitlab, "platform", "web"}); slug != want {
+		t.Errorf("got %#v, want %#v", slug, want)
+	}
+}
+
+func TestGetTreeV2(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/project/gitlab/platform/api/pipeline", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("branch") != "master" {
+			t.Errorf("expected branch=master, got %q", r.URL.RawQuery)
+		}
+		if r.Header.Get("Circle-Token") != "tok" {
+			t.Errorf("expected Circle-Token header to be set")
+		}
+		w.Write([]byte(`{"items": [{"id": "p2", "number": 8, "created_at": "2018-06-01T12:00:00Z", "vcs": {"revision": "abc"}}]}`))
+	})
+	mux.HandleFunc("/v2/pipeline/p2/workflow", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items": [{"id": "w1", "status": "running"}]}`))
+	})
+	mux.HandleFunc("/v2/workflow/w1/job", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items": [
+			{"job_number": 41, "name": "build", "status": "success", "started_at": "2018-06-01T12:00:05Z", "stopped_at": "2018-06-01T12:01:00Z"},
+			{"name": "approve", "status": "on_hold", "type": "approval"},
+			{"job_number": 42, "name": "test", "status": "blocked", "started_at": null}
+		]}`))
+	})
+	mux.HandleFunc("/v2/project/gitlab/platform/api/job/41", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number": 41, "queued_at": "2018-06-01T12:00:02Z", "started_at": "2018-06-01T12:00:05Z"}`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+	p := &project{
+		slug: ProjectSlug{VCSTypeGitlab, "platform", "api"},
+		cfg:  organization{Token: "tok", APIBase: s.URL},
+		org:  "Platform",
+	}
+	cr, err := p.getTreeV2(context.Background(), "master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*cr) != 2 {
+		t.Fatalf("expected 2 builds, got %d", len(*cr))
+	}
+	latest := (*cr)[0]
+	if latest.BuildNum != 42 {
+		t.Errorf("expected latest build to be 42, got %d", latest.BuildNum)
+	}
+	if !latest.NotRunning() {
+		t.Errorf("expected blocked job to count as not running, status was %q", latest.Status)
+	}
+	if latest.Username != "Platform" {
+		t.Errorf("expected username to be the configured org, got %q", latest.Username)
+	}
+	if latest.VCSRevision != "abc" {
+		t.Errorf("expected revision abc, got %q", latest.VCSRevision)
+	}
+	if !(*cr)[1].Passed() {
+		t.Errorf("expected build 41 to pass")
+	}
+	// the queue time comes from the job, not from when the pipeline was
+	// created.
+	if wait, ok := (*cr)[1].QueueTime(); !ok || wait != 3*time.Second {
+		t.Errorf("expected build 41 to wait 3s, got %v (%t)", wait, ok)
+	}
+}
+
+func TestArtifactsV2(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/project/circleci/org-id/project-id/7/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page-token") == "" {
+			w.Write([]byte(`{"items": [{"path": "coverage.out", "node_index": 0, "url": "https://example.com/0/coverage.out"}], "next_page_token": "next"}`))
+			return
+		}
+		w.Write([]byte(`{"items": [{"path": "coverage.out", "node_index": 1, "url": "https://example.com/1/coverage.out"}], "next_page_token": null}`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+	p := &project{
+		slug: ProjectSlug{VCSTypeCircleCI, "org-id", "project-id"},
+		cfg:  organization{Token: "tok", APIBase: s.URL},
+	}
+	arts, err := p.artifactsV2(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(arts))
+	}
+	if arts[1].NodeIndex != 1 || arts[1].PrettyPath != "coverage.out" {
+		t.Errorf("unexpected artifact: %+v", arts[1])
+	}
+}
@@ -0,0 +1,81 @@
+// Package stats computes summary statistics, like medians and percentiles, for
+// build and test timings.
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Percentile returns the pth percentile of values, for p between 0 and 100,
+// interpolating between the closest two values. It returns 0 if values is
+// empty. values is not modified.
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := math.Floor(rank)
+	i := int(lo)
+	if i+1 >= len(sorted) {
+		return sorted[i]
+	}
+	return sorted[i] + (rank-lo)*(sorted[i+1]-sorted[i])
+}
+
+// Median returns the median of values, or 0 if values is empty.
+func Median(values []float64) float64 {
+	return Percentile(values, 50)
+}
+
+// Max returns the largest of values, or 0 if values is empty.
+func Max(values []float64) float64 {
+	return Percentile(values, 100)
+}
+
+// DurationPercentile is like Percentile, for durations.
+func DurationPercentile(durations []time.Duration, p float64) time.Duration {
+	values := make([]float64, len(durations))
+	for i, d := range durations {
+		values[i] = float64(d)
+	}
+	return time.Duration(Percentile(values, p))
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a line of block characters, scaled so the
+// smallest value is the lowest block and the largest is the highest. Negative
+// values are gaps, drawn as spaces.
+func Sparkline(values []float64) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if v < 0 {
+			continue
+		}
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	line := make([]rune, len(values))
+	for i, v := range values {
+		switch {
+		case v < 0:
+			line[i] = ' '
+		case hi == lo:
+			line[i] = sparks[len(sparks)/2]
+		default:
+			line[i] = sparks[int((v-lo)/(hi-lo)*float64(len(sparks)-1)+0.5)]
+		}
+	}
+	return string(line)
+}
@@ -0,0 +1,58 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{90, 4.6},
+		{100, 5},
+		{25, 2},
+	}
+	for _, tt := range tests {
+		if got := Percentile(values, tt.p); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("Percentile(%v): got %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if values[0] != 5 {
+		t.Errorf("Percentile should not modify its input")
+	}
+	if got := Median(nil); got != 0 {
+		t.Errorf("Median(nil): got %v, want 0", got)
+	}
+	if got := Median([]float64{1, 2}); got != 1.5 {
+		t.Errorf("Median: got %v, want 1.5", got)
+	}
+	if got := Max([]float64{7}); got != 7 {
+		t.Errorf("Max: got %v, want 7", got)
+	}
+	d := DurationPercentile([]time.Duration{time.Second, 3 * time.Second}, 50)
+	if d != 2*time.Second {
+		t.Errorf("DurationPercentile: got %v, want 2s", d)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		in   []float64
+		want string
+	}{
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8}, "▁▂▃▄▅▆▇█"},
+		{[]float64{0, -1, 10}, "▁ █"},
+		{[]float64{3, 3}, "▅▅"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Sparkline(tt.in); got != tt.want {
+			t.Errorf("Sparkline(%v): got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package circle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestResult is a single test, from the JUnit XML files a build stores with
+// store_test_results.
+type TestResult struct {
+	Name      string `json:"name"`
+	Classname string `json:"classname"`
+	File      string `json:"file"`
+	// Result is "success", "failure", "error" or "skipped".
+	Result  string `json:"result"`
+	Message string `json:"message"`
+	Source  string `json:"source"`
+	// RunTime is the test's run time in seconds.
+	RunTime float64 `json:"run_time"`
+}
+
+// Failed reports whether the test failed.
+func (t *TestResult) Failed() bool {
+	return t.Result == "failure" || t.Result == "error"
+}
+
+// Duration returns the test's run time.
+func (t *TestResult) Duration() time.Duration {
+	return time.Duration(t.RunTime * float64(time.Second))
+}
+
+// FullName returns the test's name, with the class name (for Go tests, the
+// package) if there is one.
+func (t *TestResult) FullName() string {
+	if t.Classname == "" {
+		return t.Name
+	}
+	return fmt.Sprintf("%s (%s)", t.Name, t.Classname)
+}
+
+type v11TestResults struct {
+	Tests []*TestResult `json:"tests"`
+}
+
+type testResultPage struct {
+	Items         []*TestResult `json:"items"`
+	NextPageToken string        `json:"next_page_token"`
+}
+
+// TestResults returns the test metadata for a build. If the build didn't
+// store any test results, TestResults returns an empty slice.
+func TestResults(ctx context.Context, host, org, project string, buildNum int) ([]*TestResult, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/%d/tests", buildNum)
+	if p.slug.hasV11() {
+		resp := new(v11TestResults)
+		if err := p.request(ctx, "GET", path, nil, resp); err != nil {
+			return nil, err
+		}
+		if resp.Tests == nil {
+			return []*TestResult{}, nil
+		}
+		return resp.Tests, nil
+	}
+	results := make([]*TestResult, 0)
+	token := ""
+	for {
+		uri := path
+		if token != "" {
+			uri += "?page-token=" + url.QueryEscape(token)
+		}
+		page := new(testResultPage)
+		if err := p.request(ctx, "GET", uri, nil, page); err != nil {
+			return nil, err
+		}
+		results = append(results, page.Items...)
+		if page.NextPageToken == "" {
+			return results, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// FailedTests returns the tests in results that failed.
+func FailedTests(results []*TestResult) []*TestResult {
+	failed := make([]*TestResult, 0)
+	for _, t := range results {
+		if t.Failed() {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// SlowestTests returns the n tests in results that took the longest,
+// slowest first.
+func SlowestTests(results []*TestResult, n int) []*TestResult {
+	sorted := make([]*TestResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].RunTime > sorted[j].RunTime })
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// TestFailureSummary returns a description of each failed test in results,
+// with its message.
+func TestFailureSummary(results []*TestResult) string {
+	var buf bytes.Buffer
+	for _, t := range FailedTests(results) {
+		fmt.Fprintf(&buf, "--- FAIL: %s (%s)\n", t.FullName(), t.Duration().Round(time.Millisecond))
+		if t.File != "" {
+			fmt.Fprintf(&buf, "    %s\n", t.File)
+		}
+		for _, line := range splitMessage(t.Message) {
+			fmt.Fprintf(&buf, "    %s\n", line)
+		}
+	}
+	return buf.String()
+}
+
+func splitMessage(msg string) []string {
+	msg = strings.TrimRight(strings.Replace(msg, "\r\n", "\n", -1), "\n")
+	if msg == "" {
+		return nil
+	}
+	return strings.Split(msg, "\n")
+}
@@ -0,0 +1,36 @@
+package circle
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testsResponse = `{"tests": [
+{"classname": "example.com/math", "file": "math_test.go", "name": "TestSub", "result": "failure", "run_time": 0.012, "message": "math_test.go:20: got 3, want 4\n", "source": "unknown"},
+{"classname": "example.com/math", "name": "TestAdd", "result": "success", "run_time": 1.5, "message": null},
+{"classname": "example.com/math", "name": "TestSkip", "result": "skipped", "run_time": 0, "message": null}
+], "exceptions": null}`
+
+func TestTestResults(t *testing.T) {
+	resp := new(v11TestResults)
+	if err := json.Unmarshal([]byte(testsResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+	failed := FailedTests(resp.Tests)
+	if len(failed) != 1 || failed[0].Name != "TestSub" {
+		t.Fatalf("unexpected failed tests: %+v", failed)
+	}
+	slowest := SlowestTests(resp.Tests, 2)
+	if len(slowest) != 2 || slowest[0].Name != "TestAdd" || slowest[1].Name != "TestSub" {
+		t.Errorf("unexpected slowest tests: %v, %v", slowest[0].Name, slowest[1].Name)
+	}
+	summary := TestFailureSummary(resp.Tests)
+	want := "--- FAIL: TestSub (example.com/math) (12ms)\n    math_test.go\n    math_test.go:20: got 3, want 4\n"
+	if summary != want {
+		t.Errorf("summary:\n%q\nwant:\n%q", summary, want)
+	}
+	if strings.Contains(summary, "TestAdd") {
+		t.Errorf("summary should not include passing tests")
+	}
+}
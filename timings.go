@@ -0,0 +1,184 @@
+package circle
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/kevinburke/go-circle/stats"
+)
+
+// TestTiming is the typical run time of a test over several builds.
+type TestTiming struct {
+	Name      string `json:"name"`
+	Classname string `json:"classname"`
+	File      string `json:"file,omitempty"`
+	// Samples is the number of builds the test ran in.
+	Samples int `json:"samples"`
+	// RunTime is the run time in seconds.
+	RunTime float64 `json:"run_time"`
+}
+
+// FileTiming is the typical run time of all of the tests in a file over
+// several builds.
+type FileTiming struct {
+	File    string  `json:"file"`
+	Samples int     `json:"samples"`
+	RunTime float64 `json:"run_time"`
+}
+
+// Timings are the run times of the tests in a project, for splitting tests
+// between containers.
+type Timings struct {
+	// Percentile is the percentile of the samples each run time is taken
+	// from, like 50 for the median.
+	Percentile float64      `json:"percentile"`
+	Builds     int          `json:"builds"`
+	Tests      []TestTiming `json:"tests"`
+	Files      []FileTiming `json:"files"`
+}
+
+type timingKey struct {
+	classname, name, file string
+}
+
+// AggregateTimings combines the test results from several builds into one
+// run time for each test and each file, taking the given percentile of the
+// run times in each build. Skipped tests are ignored. A test that ran more
+// than once in a build, for example on several containers, counts as the sum
+// of its run times.
+func AggregateTimings(builds [][]*TestResult, percentile float64) *Timings {
+	tests := make(map[timingKey][]float64)
+	files := make(map[string][]float64)
+	count := 0
+	for _, results := range builds {
+		buildTests := make(map[timingKey]float64)
+		buildFiles := make(map[string]float64)
+		for _, t := range results {
+			if t.Result == "skipped" {
+				continue
+			}
+			buildTests[timingKey{t.Classname, t.Name, t.File}] += t.RunTime
+			if t.File != "" {
+				buildFiles[t.File] += t.RunTime
+			}
+		}
+		if len(buildTests) == 0 {
+			continue
+		}
+		count++
+		for k, v := range buildTests {
+			tests[k] = append(tests[k], v)
+		}
+		for k, v := range buildFiles {
+			files[k] = append(files[k], v)
+		}
+	}
+	timings := &Timings{
+		Percentile: percentile,
+		Builds:     count,
+		Tests:      make([]TestTiming, 0, len(tests)),
+		Files:      make([]FileTiming, 0, len(files)),
+	}
+	for k, samples := range tests {
+		timings.Tests = append(timings.Tests, TestTiming{
+			Name:      k.name,
+			Classname: k.classname,
+			File:      k.file,
+			Samples:   len(samples),
+			RunTime:   stats.Percentile(samples, percentile),
+		})
+	}
+	sort.Slice(timings.Tests, func(i, j int) bool {
+		a, b := timings.Tests[i], timings.Tests[j]
+		if a.Classname != b.Classname {
+			return a.Classname < b.Classname
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.File < b.File
+	})
+	for file, samples := range files {
+		timings.Files = append(timings.Files, FileTiming{
+			File:    file,
+			Samples: len(samples),
+			RunTime: stats.Percentile(samples, percentile),
+		})
+	}
+	sort.Slice(timings.Files, func(i, j int) bool { return timings.Files[i].File < timings.Files[j].File })
+	return timings
+}
+
+// WriteJSON writes t to w as indented JSON.
+func (t *Timings) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+type junitOutputSuites struct {
+	XMLName xml.Name           `xml:"testsuites"`
+	Suites  []junitOutputSuite `xml:"testsuite"`
+}
+
+type junitOutputSuite struct {
+	Name  string            `xml:"name,attr"`
+	Tests int               `xml:"tests,attr"`
+	Time  string            `xml:"time,attr"`
+	Cases []junitOutputCase `xml:"testcase"`
+}
+
+type junitOutputCase struct {
+	Name      string `xml:"name,attr"`
+	Classname string `xml:"classname,attr"`
+	File      string `xml:"file,attr,omitempty"`
+	Time      string `xml:"time,attr"`
+}
+
+func formatSeconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+// WriteJUnit writes t to w as a JUnit XML report with one test suite per
+// class name. "circleci tests split --split-by=timings" reads the file and
+// classname attributes from reports like this one.
+func (t *Timings) WriteJUnit(w io.Writer) error {
+	out := junitOutputSuites{}
+	var suite *junitOutputSuite
+	var total float64
+	for _, tt := range t.Tests {
+		if suite == nil || suite.Name != tt.Classname {
+			if suite != nil {
+				suite.Time = formatSeconds(total)
+				out.Suites = append(out.Suites, *suite)
+			}
+			suite = &junitOutputSuite{Name: tt.Classname}
+			total = 0
+		}
+		suite.Tests++
+		total += tt.RunTime
+		suite.Cases = append(suite.Cases, junitOutputCase{
+			Name:      tt.Name,
+			Classname: tt.Classname,
+			File:      tt.File,
+			Time:      formatSeconds(tt.RunTime),
+		})
+	}
+	if suite != nil {
+		suite.Time = formatSeconds(total)
+		out.Suites = append(out.Suites, *suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
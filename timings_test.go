@@ -0,0 +1,80 @@
+package circle
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+	<testsuite name="example.com/math" tests="3">
+		<testcase classname="example.com/math" name="TestAdd" file="math_test.go" time="1.5"></testcase>
+		<testcase name="TestSub" file="math_test.go" time="0.5">
+			<failure message="Failed">math_test.go:20: got 3, want 4</failure>
+		</testcase>
+		<testcase classname="example.com/math" name="TestSkip" time="0">
+			<skipped message="skipped"></skipped>
+		</testcase>
+	</testsuite>
+</testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	results, err := ParseJUnit(strings.NewReader(junitReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	sub := results[1]
+	if sub.Classname != "example.com/math" || sub.Result != "failure" || sub.RunTime != 0.5 {
+		t.Errorf("unexpected result: %+v", sub)
+	}
+	if sub.Message != "math_test.go:20: got 3, want 4" {
+		t.Errorf("unexpected message: %q", sub.Message)
+	}
+	if results[2].Result != "skipped" {
+		t.Errorf("expected skipped result, got %q", results[2].Result)
+	}
+	if _, err := ParseJUnit(strings.NewReader(`<html><body></body></html>`)); err != ErrNotJUnit {
+		t.Errorf("expected ErrNotJUnit for HTML, got %v", err)
+	}
+	if _, err := ParseJUnit(strings.NewReader(`mode: set`)); err != ErrNotJUnit {
+		t.Errorf("expected ErrNotJUnit for text, got %v", err)
+	}
+}
+
+func TestAggregateTimings(t *testing.T) {
+	build := func(add, sub float64) []*TestResult {
+		return []*TestResult{
+			{Name: "TestAdd", Classname: "example.com/math", File: "math_test.go", Result: "success", RunTime: add},
+			{Name: "TestSub", Classname: "example.com/math", File: "math_test.go", Result: "failure", RunTime: sub},
+			{Name: "TestSkip", Classname: "example.com/math", Result: "skipped"},
+		}
+	}
+	timings := AggregateTimings([][]*TestResult{build(1, 2), build(3, 2), build(2, 5), nil}, 50)
+	if timings.Builds != 3 {
+		t.Errorf("expected 3 builds, got %d", timings.Builds)
+	}
+	if len(timings.Tests) != 2 {
+		t.Fatalf("expected 2 tests, got %d: %+v", len(timings.Tests), timings.Tests)
+	}
+	if add := timings.Tests[0]; add.Name != "TestAdd" || add.RunTime != 2 || add.Samples != 3 {
+		t.Errorf("unexpected TestAdd timing: %+v", add)
+	}
+	if len(timings.Files) != 1 || timings.Files[0].RunTime != 5 {
+		t.Errorf("unexpected file timings: %+v", timings.Files)
+	}
+	var buf bytes.Buffer
+	if err := timings.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ParseJUnit(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].File != "math_test.go" || results[0].RunTime != 2 {
+		t.Errorf("JUnit output did not round trip: %+v", results)
+	}
+}
@@ -2,22 +2,134 @@ package circle
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/kevinburke/rest"
 )
 
+const defaultAPIBase = "https://circleci.com/api"
+
 type CircleConfig struct {
 	Organizations map[string]organization
+
+	// VCSHosts maps a Git host to the VCS type CircleCI uses for it, for
+	// example "ghe.corp.example" = "github". github.com, bitbucket.org and
+	// gitlab.com don't need an entry.
+	VCSHosts map[string]VCS `toml:"vcs_hosts"`
+
+	// Projects maps a Git project, like "gitlab.com/group/repo", to the slug
+	// CircleCI uses for it, like "circleci/<org-id>/<project-id>". Only
+	// projects that CircleCI doesn't identify by their Git path need an entry.
+	Projects map[string]string
 }
 
 type organization struct {
 	Token string
+
+	// Host is the hostname of a CircleCI Server installation, for example
+	// "circleci.corp.example". If empty, circleci.com is used.
+	Host string
+	// APIBase overrides the root of the API, for example
+	// "https://circleci.corp.example/api". Defaults to "https://<host>/api".
+	APIBase string `toml:"api_base"`
+	// CABundle is the path to a file with PEM encoded certificates that
+	// should be trusted in addition to the system roots.
+	CABundle string `toml:"ca_bundle"`
+	// Proxy is the URL of a HTTP proxy to use for all requests. If empty,
+	// the HTTP_PROXY and HTTPS_PROXY environment variables are used.
+	Proxy string
+}
+
+// apiBase returns the root of the CircleCI API for the organization, without
+// a trailing slash.
+func (o organization) apiBase() string {
+	if o.APIBase != "" {
+		return strings.TrimSuffix(o.APIBase, "/")
+	}
+	if o.Host != "" {
+		return "https://" + o.Host + "/api"
+	}
+	return defaultAPIBase
+}
+
+func (o organization) v11Base() string {
+	return o.apiBase() + "/v1.1/project"
+}
+
+func (o organization) v2Base() string {
+	return o.apiBase() + "/v2"
+}
+
+var clientsMu sync.Mutex
+var clients = make(map[organization]*http.Client)
+
+// httpClient returns a HTTP client that trusts the organization's CA bundle
+// and uses its proxy, if either is configured. Clients are cached so
+// connections can be reused across requests.
+func (o organization) httpClient() (*http.Client, error) {
+	if o.CABundle == "" && o.Proxy == "" {
+		return &http.Client{Transport: rest.DefaultTransport}, nil
+	}
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[o]; ok {
+		return c, nil
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if o.Proxy != "" {
+		u, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %v", o.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if o.CABundle != "" {
+		data, err := ioutil.ReadFile(o.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", o.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	c := &http.Client{
+		Transport: &rest.Transport{
+			RoundTripper: transport,
+			Debug:        rest.DefaultTransport.Debug,
+			Output:       rest.DefaultTransport.Output,
+		},
+	}
+	clients[o] = c
+	return c, nil
 }
 
 // getCaseInsensitiveOrg finds the key in the list of orgs. This is a case
@@ -42,7 +154,15 @@ Go to https://circleci.com/account/api if you need to create a token.
 	}
 }
 
-func getToken(orgName string) (string, error) {
+// homeDir returns the current user's home directory.
+func homeDir() string {
+	if user, err := user.Current(); err == nil {
+		return user.HomeDir
+	}
+	return os.Getenv("HOME")
+}
+
+func getConfig() (*CircleConfig, error) {
 	var filename string
 	var f io.ReadCloser
 	var err error
@@ -52,13 +172,7 @@ func getToken(orgName string) (string, error) {
 		f, err = os.Open(filename)
 		checkedLocations[0] = filename
 	} else {
-		var homeDir string
-		user, userErr := user.Current()
-		if userErr == nil {
-			homeDir = user.HomeDir
-		} else {
-			homeDir = os.Getenv("HOME")
-		}
+		homeDir := homeDir()
 		filename = filepath.Join(homeDir, "cfg", "circleci")
 		f, err = os.Open(filename)
 		checkedLocations[0] = filename
@@ -80,17 +194,20 @@ Add a configuration file with your CircleCI token, like this:
 
 Go to https://circleci.com/account/api if you need to create a token.
 `, strings.Join(checkedLocations, " or "))
-		return "", err
+		return nil, err
 	}
 	defer f.Close()
-	var c CircleConfig
-	_, err = toml.DecodeReader(bufio.NewReader(f), &c)
-	if err != nil {
-		return "", err
+	c := new(CircleConfig)
+	if _, err := toml.DecodeReader(bufio.NewReader(f), c); err != nil {
+		return nil, err
 	}
-	org, err := getCaseInsensitiveOrg(orgName, c.Organizations)
+	return c, nil
+}
+
+func getOrganization(orgName string) (organization, error) {
+	c, err := getConfig()
 	if err != nil {
-		return "", err
+		return organization{}, err
 	}
-	return org.Token, nil
+	return getCaseInsensitiveOrg(orgName, c.Organizations)
 }
@@ -3,6 +3,8 @@ package circle
 import (
 	"strings"
 	"testing"
+
+	"github.com/BurntSushi/toml"
 )
 
 func TestCaseInsensitive(t *testing.T) {
@@ -13,7 +15,7 @@ func TestCaseInsensitive(t *testing.T) {
 	}
 	o, err := getCaseInsensitiveOrg("ShyP", cfg.Organizations)
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err)
 	}
 	if o.Token != "foo" {
 		t.Fatalf("expected o.Token to be foo, was %v", o.Token)
@@ -27,3 +29,83 @@ func TestCaseInsensitive(t *testing.T) {
 		t.Fatalf("expected Couldn't find error message, got %v", err)
 	}
 }
+
+const selfHostedConfig = `
+[organizations]
+
+    [organizations.kevinburke]
+    token = "aabbccddeeff00"
+
+    [organizations.corp]
+    token = "112233"
+    host = "circleci.corp.example"
+    ca_bundle = "/etc/ssl/corp.pem"
+    proxy = "http://proxy.corp.example:3128"
+
+    [organizations.other]
+    token = "445566"
+    api_base = "https://ci.corp.example/circle/api/"
+
+[vcs_hosts]
+"ghe.corp.example" = "github"
+`
+
+func TestSelfHostedConfig(t *testing.T) {
+	var c CircleConfig
+	if _, err := toml.Decode(selfHostedConfig, &c); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		org  string
+		want string
+	}{
+		{"kevinburke", "https://circleci.com/api/v1.1/project"},
+		{"corp", "https://circleci.corp.example/api/v1.1/project"},
+		{"other", "https://ci.corp.example/circle/api/v1.1/project"},
+	}
+	for _, tt := range tests {
+		o, err := getCaseInsensitiveOrg(tt.org, c.Organizations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := o.v11Base(); got != tt.want {
+			t.Errorf("%s: expected v11Base to be %q, got %q", tt.org, tt.want, got)
+		}
+	}
+	corp, _ := getCaseInsensitiveOrg("corp", c.Organizations)
+	if corp.CABundle != "/etc/ssl/corp.pem" {
+		t.Errorf("expected CA bundle to be /etc/ssl/corp.pem, got %q", corp.CABundle)
+	}
+	if corp.Proxy != "http://proxy.corp.example:3128" {
+		t.Errorf("expected proxy to be set, got %q", corp.Proxy)
+	}
+	vcs, err := vcsType("ghe.corp.example", c.VCSHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vcs != VCSTypeGithub {
+		t.Errorf("expected ghe.corp.example to map to github, got %q", vcs)
+	}
+	if _, err := vcsType("git.unknown.example", c.VCSHosts); err == nil {
+		t.Errorf("expected unknown host to return an error")
+	}
+}
+
+func TestHTTPClientBadCABundle(t *testing.T) {
+	o := organization{Token: "foo", CABundle: "testdata/does-not-exist.pem"}
+	if _, err := o.httpClient(); err == nil {
+		t.Fatal("expected missing CA bundle to return an error")
+	}
+	o = organization{Token: "foo", Proxy: "http://proxy.example:3128"}
+	c, err := o.httpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := o.httpClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != c2 {
+		t.Errorf("expected clients to be cached")
+	}
+}
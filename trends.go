@@ -0,0 +1,116 @@
+package circle
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kevinburke/go-circle/stats"
+)
+
+// StepSample is the run time of a step in one build.
+type StepSample struct {
+	BuildNum int
+	// Time is the time the build started.
+	Time    time.Time
+	Runtime time.Duration
+}
+
+// StepTrend is the run time of a step over many builds.
+type StepTrend struct {
+	Name string
+	// Samples are sorted by build number, oldest first.
+	Samples []StepSample
+}
+
+func (t *StepTrend) runtimes() []time.Duration {
+	durations := make([]time.Duration, len(t.Samples))
+	for i, s := range t.Samples {
+		durations[i] = s.Runtime
+	}
+	return durations
+}
+
+// Percentile returns the pth percentile of the step's run times.
+func (t *StepTrend) Percentile(p float64) time.Duration {
+	return stats.DurationPercentile(t.runtimes(), p)
+}
+
+// Buckets divides the time from start to end into n periods of the same
+// length, and returns the pth percentile of the run times in each one. Periods
+// without any samples are -1.
+func (t *StepTrend) Buckets(start, end time.Time, n int, p float64) []time.Duration {
+	buckets := make([][]time.Duration, n)
+	width := end.Sub(start) / time.Duration(n)
+	for _, s := range t.Samples {
+		if s.Time.Before(start) || s.Time.After(end) || width <= 0 {
+			continue
+		}
+		i := int(s.Time.Sub(start) / width)
+		if i >= n {
+			i = n - 1
+		}
+		buckets[i] = append(buckets[i], s.Runtime)
+	}
+	values := make([]time.Duration, n)
+	for i, b := range buckets {
+		if len(b) == 0 {
+			values[i] = -1
+			continue
+		}
+		values[i] = stats.DurationPercentile(b, p)
+	}
+	return values
+}
+
+func buildStart(cb *CircleBuild) time.Time {
+	if cb.StartTime.Valid {
+		return cb.StartTime.Time
+	}
+	if cb.QueuedAt.Valid {
+		return cb.QueuedAt.Time
+	}
+	return cb.UsageQueuedAt.Time
+}
+
+// StepTrends collects the run time of each step over builds. A step's run time
+// is the longest time any container took to run it; steps that didn't finish
+// are skipped. If a build runs several steps with the same name, the second
+// one is named like "make test (2)". Steps are in the order they ran in the
+// most recent build, followed by steps that only ran in older builds.
+func StepTrends(builds []*CircleBuild) []*StepTrend {
+	sorted := make([]*CircleBuild, len(builds))
+	copy(sorted, builds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].BuildNum > sorted[j].BuildNum })
+	trends := make(map[string]*StepTrend)
+	var order []*StepTrend
+	for _, cb := range sorted {
+		seen := make(map[string]int)
+		for _, step := range cb.Steps {
+			name := step.Name
+			seen[step.Name]++
+			if n := seen[step.Name]; n > 1 {
+				name = fmt.Sprintf("%s (%d)", step.Name, n)
+			}
+			d := stepRuntime(step)
+			if d < 0 {
+				continue
+			}
+			trend, ok := trends[name]
+			if !ok {
+				trend = &StepTrend{Name: name}
+				trends[name] = trend
+				order = append(order, trend)
+			}
+			trend.Samples = append(trend.Samples, StepSample{
+				BuildNum: int(cb.BuildNum),
+				Time:     buildStart(cb),
+				Runtime:  d,
+			})
+		}
+	}
+	for _, trend := range order {
+		sort.Slice(trend.Samples, func(i, j int) bool { return trend.Samples[i].BuildNum < trend.Samples[j].BuildNum })
+	}
+	return order
+}
@@ -0,0 +1,55 @@
+package circle
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+)
+
+func TestStepTrends(t *testing.T) {
+	start := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	build := func(num uint32, day int, steps ...Step) *CircleBuild {
+		return &CircleBuild{
+			BuildNum:  num,
+			StartTime: types.NullTime{Valid: true, Time: start.AddDate(0, 0, day)},
+			Steps:     steps,
+		}
+	}
+	step := func(name string, runtimes ...time.Duration) Step {
+		s := Step{Name: name}
+		for i, d := range runtimes {
+			s.Actions = append(s.Actions, Action{Index: uint16(i), Runtime: CircleDuration(d)})
+		}
+		return s
+	}
+	builds := []*CircleBuild{
+		build(1, 0, step("checkout", time.Second), step("make test", 10*time.Second, 20*time.Second), step("lint", 5*time.Second)),
+		build(3, 2, step("checkout", time.Second), step("make test", 40*time.Second), step("make test", time.Second)),
+		build(2, 1, step("checkout", time.Second), step("make test", 30*time.Second, -1)),
+	}
+	trends := StepTrends(builds)
+	names := []string{"checkout", "make test", "make test (2)", "lint"}
+	if len(trends) != len(names) {
+		t.Fatalf("expected %d trends, got %d", len(names), len(trends))
+	}
+	for i, name := range names {
+		if trends[i].Name != name {
+			t.Errorf("trend %d: got %q, want %q", i, trends[i].Name, name)
+		}
+	}
+	test := trends[1]
+	if len(test.Samples) != 3 || test.Samples[0].BuildNum != 1 || test.Samples[0].Runtime != 20*time.Second {
+		t.Fatalf("unexpected samples: %+v", test.Samples)
+	}
+	if p50 := test.Percentile(50); p50 != 30*time.Second {
+		t.Errorf("expected median 30s, got %v", p50)
+	}
+	buckets := test.Buckets(start, start.AddDate(0, 0, 4), 4, 50)
+	want := []time.Duration{20 * time.Second, 30 * time.Second, 40 * time.Second, -1}
+	for i := range want {
+		if buckets[i] != want[i] {
+			t.Errorf("bucket %d: got %v, want %v", i, buckets[i], want[i])
+		}
+	}
+}
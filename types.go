@@ -47,3 +47,21 @@ func (cd *CircleDuration) UnmarshalJSON(b []byte) error {
 	*cd = CircleDuration(d * time.Millisecond)
 	return nil
 }
+
+// MarshalJSON encodes the URL as a string, the way CircleCI sends it, or null
+// if it is empty.
+func (oururl URL) MarshalJSON() ([]byte, error) {
+	if oururl.URL == nil {
+		return null, nil
+	}
+	return json.Marshal(oururl.URL.String())
+}
+
+// MarshalJSON encodes the duration in milliseconds, the way CircleCI sends
+// it, or null if the duration is unknown.
+func (cd CircleDuration) MarshalJSON() ([]byte, error) {
+	if cd == CircleDuration(-1) {
+		return null, nil
+	}
+	return json.Marshal(int64(time.Duration(cd) / time.Millisecond))
+}
@@ -42,3 +42,33 @@ func TestString(t *testing.T) {
 		t.Fatalf("expected cu.String() to be https://foo.com, was %s", cu.String())
 	}
 }
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := Action{Runtime: CircleDuration(1500 * time.Millisecond)}
+	u, _ := url.Parse("https://circle-production-action-output.s3.amazonaws.com/abc")
+	in.OutputURL = URL{u}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Action
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Runtime != in.Runtime {
+		t.Errorf("Runtime: got %v, want %v", time.Duration(out.Runtime), time.Duration(in.Runtime))
+	}
+	if out.OutputURL.String() != u.String() {
+		t.Errorf("OutputURL: got %q, want %q", out.OutputURL.String(), u.String())
+	}
+	data, err = json.Marshal(Action{Runtime: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Runtime != -1 {
+		t.Errorf("expected unknown run time to round trip, got %v", out.Runtime)
+	}
+}
@@ -0,0 +1,381 @@
+package circle
+
+// The v2 API organizes builds into pipelines, workflows and jobs. GitLab
+// projects and projects that use a "circleci" slug are only available through
+// the v2 API, so we translate its responses into the v1.1 types the rest of
+// the library uses.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	types "github.com/kevinburke/go-types"
+	"golang.org/x/sync/errgroup"
+)
+
+type Pipeline struct {
+	ID        string      `json:"id"`
+	Number    int         `json:"number"`
+	State     string      `json:"state"`
+	CreatedAt time.Time   `json:"created_at"`
+	VCS       PipelineVCS `json:"vcs"`
+}
+
+type PipelineVCS struct {
+	Branch   string `json:"branch"`
+	Revision string `json:"revision"`
+}
+
+type Workflow struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	PipelineID     string         `json:"pipeline_id"`
+	PipelineNumber int            `json:"pipeline_number"`
+	Status         string         `json:"status"`
+	CreatedAt      time.Time      `json:"created_at"`
+	StoppedAt      types.NullTime `json:"stopped_at"`
+}
+
+type Job struct {
+	ID        string         `json:"id"`
+	JobNumber int            `json:"job_number"`
+	Name      string         `json:"name"`
+	Status    string         `json:"status"`
+	Type      string         `json:"type"`
+	StartedAt types.NullTime `json:"started_at"`
+	StoppedAt types.NullTime `json:"stopped_at"`
+}
+
+// jobDetail is the response from the v2 job endpoint.
+type jobDetail struct {
+	Number      int            `json:"number"`
+	Status      string         `json:"status"`
+	Parallelism uint8          `json:"parallelism"`
+	QueuedAt    types.NullTime `json:"queued_at"`
+	StartedAt   types.NullTime `json:"started_at"`
+	StoppedAt   types.NullTime `json:"stopped_at"`
+	WebURL      string         `json:"web_url"`
+}
+
+type pipelinePage struct {
+	Items         []*Pipeline `json:"items"`
+	NextPageToken string      `json:"next_page_token"`
+}
+
+type workflowPage struct {
+	Items         []*Workflow `json:"items"`
+	NextPageToken string      `json:"next_page_token"`
+}
+
+type jobPage struct {
+	Items         []*Job `json:"items"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+type artifactPage struct {
+	Items         []*CircleArtifact `json:"items"`
+	NextPageToken string            `json:"next_page_token"`
+}
+
+// ErrFollowV2 is returned by Enable for projects that are only available
+// through the v2 API. Those projects are built without being followed.
+var ErrFollowV2 = errors.New("following projects is only available for GitHub and Bitbucket projects")
+
+// v2JobStatuses maps v2 job statuses that have no v1.1 equivalent onto the
+// closest v1.1 status. Statuses not in the map are the same in both APIs.
+var v2JobStatuses = map[string]string{
+	"blocked":      "not_running",
+	"on_hold":      "not_running",
+	"error":        "infrastructure_fail",
+	"unauthorized": "failed",
+}
+
+func v11Status(v2Status string) string {
+	if s, ok := v2JobStatuses[v2Status]; ok {
+		return s
+	}
+	return v2Status
+}
+
+// GetPipelines returns the most recent page of pipelines for the project. If
+// branch is not empty, only pipelines for that branch are returned.
+func GetPipelines(ctx context.Context, host, org, project, branch string) ([]*Pipeline, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	return p.pipelines(ctx, branch)
+}
+
+// GetPipelineWorkflows returns the workflows for the pipeline with the given
+// ID.
+func GetPipelineWorkflows(ctx context.Context, host, org, project, pipelineID string) ([]*Workflow, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	return p.workflows(ctx, pipelineID)
+}
+
+// GetWorkflowJobs returns the jobs in the workflow with the given ID.
+func GetWorkflowJobs(ctx context.Context, host, org, project, workflowID string) ([]*Job, error) {
+	p, err := getProject(host, org, project)
+	if err != nil {
+		return nil, err
+	}
+	return p.jobs(ctx, workflowID)
+}
+
+func (p *project) pipelines(ctx context.Context, branch string) ([]*Pipeline, error) {
+	page, err := p.pipelinePage(ctx, branch, "")
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// pipelinePage returns the page of pipelines on branch that starts at token,
+// or the first page if token is empty.
+func (p *project) pipelinePage(ctx context.Context, branch, token string) (*pipelinePage, error) {
+	query := url.Values{}
+	if branch != "" {
+		query.Set("branch", branch)
+	}
+	if token != "" {
+		query.Set("page-token", token)
+	}
+	uri := fmt.Sprintf("/project/%s/pipeline", p.slug)
+	if len(query) > 0 {
+		uri += "?" + query.Encode()
+	}
+	page := new(pipelinePage)
+	if err := makeV2Request(ctx, p.cfg, "GET", uri, page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (p *project) workflows(ctx context.Context, pipelineID string) ([]*Workflow, error) {
+	page := new(workflowPage)
+	if err := makeV2Request(ctx, p.cfg, "GET", "/pipeline/"+pipelineID+"/workflow", page); err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+func (p *project) jobs(ctx context.Context, workflowID string) ([]*Job, error) {
+	page := new(jobPage)
+	if err := makeV2Request(ctx, p.cfg, "GET", "/workflow/"+workflowID+"/job", page); err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// appBase returns the root URL of the CircleCI web app.
+func (o organization) appBase() string {
+	if o.Host != "" {
+		return "https://" + o.Host
+	}
+	return "https://app.circleci.com"
+}
+
+// treePipelines is the number of pipelines to fetch jobs for when building a
+// tree response from the v2 API.
+const treePipelines = 5
+
+// getTreeV2 builds a response like the one from the v1.1 tree endpoint out of
+// the jobs in the most recent pipelines on branch. Like the v1.1 endpoint,
+// builds are sorted with the most recent first.
+func (p *project) getTreeV2(ctx context.Context, branch string) (*CircleTreeResponse, error) {
+	pipelines, err := p.pipelines(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+	if len(pipelines) > treePipelines {
+		pipelines = pipelines[:treePipelines]
+	}
+	builds, err := p.pipelineBuilds(ctx, pipelines)
+	if err != nil {
+		return nil, err
+	}
+	cr := CircleTreeResponse(builds)
+	return &cr, nil
+}
+
+// historyV2 returns up to limit builds from the pipelines on branch that were
+// created after since, most recent first.
+func (p *project) historyV2(ctx context.Context, branch string, since time.Time, limit int) ([]TreeBuild, error) {
+	builds := make([]TreeBuild, 0)
+	token := ""
+	for len(builds) < limit {
+		page, err := p.pipelinePage(ctx, branch, token)
+		if err != nil {
+			return nil, err
+		}
+		pageBuilds, err := p.pipelineBuilds(ctx, page.Items)
+		if err != nil {
+			return nil, err
+		}
+		builds = append(builds, pageBuilds...)
+		var done bool
+		builds, done = queuedBefore(builds, since)
+		if done || page.NextPageToken == "" {
+			break
+		}
+		token = page.NextPageToken
+	}
+	if len(builds) > limit {
+		builds = builds[:limit]
+	}
+	return builds, nil
+}
+
+// pipelineBuilds returns the jobs in pipelines as TreeBuilds, most recent
+// first.
+func (p *project) pipelineBuilds(ctx context.Context, pipelines []*Pipeline) ([]TreeBuild, error) {
+	var mu sync.Mutex
+	builds := make([]TreeBuild, 0)
+	group, errctx := errgroup.WithContext(ctx)
+	for _, pipeline := range pipelines {
+		pipeline := pipeline
+		group.Go(func() error {
+			workflows, err := p.workflows(errctx, pipeline.ID)
+			if err != nil {
+				return err
+			}
+			for _, workflow := range workflows {
+				jobs, err := p.jobs(errctx, workflow.ID)
+				if err != nil {
+					return err
+				}
+				for _, job := range jobs {
+					if job.JobNumber == 0 {
+						// approval jobs don't have a number and can't be
+						// fetched.
+						continue
+					}
+					tb := TreeBuild{
+						Branch:   pipeline.VCS.Branch,
+						BuildNum: job.JobNumber,
+						BuildURL: fmt.Sprintf("%s/pipelines/%s/%d/workflows/%s/jobs/%d",
+							p.cfg.appBase(), p.slug, pipeline.Number, workflow.ID, job.JobNumber),
+						RepoName:    p.slug.Project,
+						Status:      v11Status(job.Status),
+						StartTime:   job.StartedAt,
+						StopTime:    job.StoppedAt,
+						Username:    p.org,
+						VCSRevision: pipeline.VCS.Revision,
+						VCSType:     string(p.slug.VCS),
+						Workflows: BuildWorkflow{
+							JobName:      job.Name,
+							WorkflowID:   workflow.ID,
+							WorkflowName: workflow.Name,
+						},
+					}
+					if !tb.StartTime.Valid {
+						// There's no queue time to measure yet, so the
+						// pipeline's creation time is close enough.
+						tb.QueuedAt = types.NullTime{Valid: true, Time: pipeline.CreatedAt}
+						mu.Lock()
+						builds = append(builds, tb)
+						mu.Unlock()
+						continue
+					}
+					// The job list doesn't say when a job was queued, and
+					// the pipeline's creation time would count the time
+					// spent waiting on earlier jobs in the workflow as
+					// queue time.
+					group.Go(func() error {
+						jd, err := p.jobDetail(errctx, tb.BuildNum)
+						if err != nil {
+							return err
+						}
+						tb.QueuedAt = jd.QueuedAt
+						mu.Lock()
+						builds = append(builds, tb)
+						mu.Unlock()
+						return nil
+					})
+				}
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].BuildNum > builds[j].BuildNum
+	})
+	return builds, nil
+}
+
+func (p *project) jobDetail(ctx context.Context, buildNum int) (*jobDetail, error) {
+	uri := fmt.Sprintf("/project/%s/job/%d", p.slug, buildNum)
+	jd := new(jobDetail)
+	if err := makeV2Request(ctx, p.cfg, "GET", uri, jd); err != nil {
+		return nil, err
+	}
+	return jd, nil
+}
+
+// getBuildV2 fetches a job from the v2 API. The v2 API doesn't return steps,
+// so the returned build has none.
+func (p *project) getBuildV2(ctx context.Context, buildNum int) (*CircleBuild, error) {
+	jd, err := p.jobDetail(ctx, buildNum)
+	if err != nil {
+		return nil, err
+	}
+	return &CircleBuild{
+		BuildNum:  uint32(jd.Number),
+		BuildURL:  jd.WebURL,
+		Parallel:  jd.Parallelism,
+		Platform:  "2.0",
+		QueuedAt:  jd.QueuedAt,
+		RepoName:  p.slug.Project,
+		StartTime: jd.StartedAt,
+		Status:    v11Status(jd.Status),
+		StopTime:  jd.StoppedAt,
+		VCSType:   string(p.slug.VCS),
+		Username:  p.org,
+	}, nil
+}
+
+// artifactsV2 returns the artifacts for a job from the v2 API.
+func (p *project) artifactsV2(ctx context.Context, buildNum int) ([]*CircleArtifact, error) {
+	arts := make([]*CircleArtifact, 0)
+	token := ""
+	for {
+		uri := fmt.Sprintf("/%d/artifacts", buildNum)
+		if token != "" {
+			uri += "?page-token=" + url.QueryEscape(token)
+		}
+		page := new(artifactPage)
+		if err := p.request(ctx, "GET", uri, nil, page); err != nil {
+			return nil, err
+		}
+		for _, art := range page.Items {
+			// the v2 API doesn't return a pretty path.
+			art.PrettyPath = art.Path
+		}
+		arts = append(arts, page.Items...)
+		if page.NextPageToken == "" {
+			return arts, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// cancelV2 cancels a job through the v2 API. The v2 API doesn't return the
+// job, so cancelV2 fetches it afterwards.
+func (p *project) cancelV2(ctx context.Context, buildNum int) (*CircleBuild, error) {
+	if err := p.request(ctx, "POST", fmt.Sprintf("/job/%d/cancel", buildNum), nil, nil); err != nil {
+		return nil, err
+	}
+	return p.getBuildV2(ctx, buildNum)
+}
@@ -17,6 +17,8 @@ import (
 
 	"github.com/kevinburke/bigtext"
 	"github.com/kevinburke/go-circle"
+	"github.com/kevinburke/go-circle/gotest"
+	"github.com/kevinburke/go-circle/quickfix"
 	"github.com/kevinburke/go-git"
 	"github.com/kevinburke/remoteci"
 	"github.com/pkg/browser"
@@ -142,12 +144,44 @@ func clear(w io.Writer, lines int) {
 }
 
 func draw(w io.Writer, build *circle.CircleBuild, prevLinesDrawn int) int {
-	stats := build.Statistics(true)
+	return drawStats(w, build.Statistics(true), prevLinesDrawn)
+}
+
+func drawStats(w io.Writer, stats string, prevLinesDrawn int) int {
 	clear(w, prevLinesDrawn)
 	io.WriteString(w, stats+"\n\033[?25l")
 	return strings.Count(stats, "\n") + 1
 }
 
+// finalStatistics returns the statistics for a finished build, compared with
+// the previous successful build if opts asks for it. If the previous build
+// can't be fetched, the statistics are returned without a comparison, along
+// with the error.
+func finalStatistics(ctx context.Context, remote *git.RemoteURL, build *circle.CircleBuild, tty bool, opts *Options) (string, error) {
+	so := &circle.StatisticsOptions{TTY: tty, Threshold: opts.Threshold}
+	var err error
+	if opts.Compare {
+		so.Previous, err = circle.GetPreviousSuccessfulBuild(ctx, remote.Host, remote.Path, remote.RepoName, build)
+	}
+	return build.StatisticsWithOptions(so), err
+}
+
+// printFinalStatistics prints the statistics for a finished build, replacing
+// the live statistics if they were drawn to a TTY.
+func printFinalStatistics(ctx context.Context, remote *git.RemoteURL, build *circle.CircleBuild, tty bool, linesDrawn int, opts *Options) {
+	stats, err := finalStatistics(ctx, remote, build, tty, opts)
+	if tty {
+		// need one last draw with the final timings
+		drawStats(os.Stdout, stats, linesDrawn)
+		clear(os.Stdout, 1)
+	} else {
+		fmt.Print(stats)
+	}
+	if err != nil {
+		fmt.Printf("error getting previous successful build: %v\n", err)
+	}
+}
+
 func isCtxCanceled(err error) bool {
 	if err == nil {
 		return false
@@ -162,7 +196,7 @@ func isCtxCanceled(err error) bool {
 	return false
 }
 
-func wait(ctx context.Context, branch, remoteStr string, rebaseAgainst string) error {
+func wait(ctx context.Context, branch, remoteStr string, rebaseAgainst string, opts *Options) error {
 	tty := remoteci.IsATTY(os.Stdout)
 	if tty {
 		defer func() {
@@ -313,15 +347,10 @@ Push output was:
 			}
 			var err error
 			detailedBuild, err = circle.GetBuild(waitCtx, remote.Host, remote.Path, remote.RepoName, latestBuild.BuildNum)
-			switch {
-			case err != nil:
+			if err != nil {
 				fmt.Printf("error getting build statistics: %v\n", err)
-			case tty:
-				// need one last draw with the final timings
-				draw(os.Stdout, detailedBuild, linesDrawn)
-				clear(os.Stdout, 1)
-			default:
-				fmt.Print(detailedBuild.Statistics(false))
+			} else {
+				printFinalStatistics(waitCtx, remote, detailedBuild, tty, linesDrawn, opts)
 			}
 			fmt.Printf(`Build on %s succeeded!
 
@@ -337,25 +366,48 @@ Tests on %s took %s. Quitting.
 			}
 			var err error
 			detailedBuild, err = circle.GetBuild(waitCtx, remote.Host, remote.Path, remote.RepoName, latestBuild.BuildNum)
-			switch {
-			case err != nil:
+			if err != nil {
 				fmt.Printf("error getting build stats: %v\n", err)
 				return err
-			case tty:
-				draw(os.Stdout, detailedBuild, linesDrawn)
-				clear(os.Stdout, 1)
-			default:
-				fmt.Print(detailedBuild.Statistics(false))
 			}
+			printFinalStatistics(waitCtx, remote, detailedBuild, tty, linesDrawn, opts)
 			failureCtx, cancel := context.WithTimeout(waitCtx, 20*time.Second)
+			var testSummary string
+			if !opts.FullOutput {
+				// test metadata is much more concise than the console output,
+				// so use it if the build stored any.
+				results, err := circle.TestResults(failureCtx, remote.Host, remote.Path, remote.RepoName, latestBuild.BuildNum)
+				if err == nil {
+					testSummary = circle.TestFailureSummary(results)
+				}
+			}
 			texts, textsErr := detailedBuild.FailureTexts(failureCtx)
 			if textsErr != nil {
 				fmt.Printf("error getting build failures: %v\n", textsErr)
 			}
 			cancel()
-			fmt.Printf("\nOutput from failed builds:\n\n")
-			for i := range texts {
-				fmt.Println(texts[i])
+			summarized := false
+			if testSummary != "" {
+				fmt.Printf("\nFailed tests:\n\n%s\n", testSummary)
+				summarized = true
+				writeOtherFailures(os.Stdout, failedActionNames(detailedBuild), texts)
+			} else {
+				fmt.Printf("\nOutput from failed builds:\n\n")
+				for i := range texts {
+					if opts.FullOutput {
+						fmt.Println(texts[i])
+					} else if ok, _ := gotest.WriteSummary(os.Stdout, texts[i]); ok {
+						summarized = true
+					}
+				}
+			}
+			if summarized {
+				fmt.Printf("Showing failing tests only, run with --full-output to see everything.\n")
+			}
+			if opts.QuickfixFile != "" {
+				if err := writeQuickfix(opts.QuickfixFile, remote, texts); err != nil {
+					fmt.Printf("error writing quickfix file: %v\n", err)
+				}
 			}
 			fmt.Printf("\nURL: %s\n", latestBuild.BuildURL)
 			err = fmt.Errorf("Build on %s failed!\n\n", branch)
@@ -443,12 +495,119 @@ Tests on %s took %s. Quitting.
 
 var errChangedRemote = errors.New("remote branch changed")
 
+// Options change how Wait reports a build.
+type Options struct {
+	// FullOutput prints the whole output of each failed action, instead of
+	// only the failing Go tests.
+	FullOutput bool
+	// QuickfixFile, if set, is a file to write the locations of errors in
+	// failed actions to, in a format editors can load.
+	QuickfixFile string
+	// Compare adds a column to the final statistics with the change in each
+	// step's run time since the previous successful build.
+	Compare bool
+	// Threshold is how much slower a step has to get before the change is
+	// highlighted. Zero disables highlighting.
+	Threshold time.Duration
+}
+
+// failedActionNames returns a name for each failed action in cb, in the same
+// order as FailureTexts.
+func failedActionNames(cb *circle.CircleBuild) []string {
+	names := make([]string, 0)
+	for _, step := range cb.Steps {
+		for _, action := range step.Actions {
+			if !action.Failed() {
+				continue
+			}
+			if len(step.Actions) > 1 {
+				names = append(names, fmt.Sprintf("%s (node %d)", step.Name, action.Index))
+			} else {
+				names = append(names, step.Name)
+			}
+		}
+	}
+	return names
+}
+
+// writeOtherFailures writes the output of the failed actions that the test
+// metadata doesn't cover. Steps with failing Go tests only get their build
+// failures and other package-level errors written, since the failing tests
+// were already printed; every other failed step, like a linter, gets its
+// whole output.
+func writeOtherFailures(w io.Writer, names, texts []string) {
+	wroteHeader := false
+	for i, text := range texts {
+		failures := gotest.Parse(text)
+		hasTests := false
+		var other []gotest.Failure
+		for _, f := range failures {
+			if f.Test != "" {
+				hasTests = true
+			} else {
+				other = append(other, f)
+			}
+		}
+		if hasTests && len(other) == 0 {
+			continue
+		}
+		if !wroteHeader {
+			fmt.Fprintf(w, "Output from other failed steps:\n\n")
+			wroteHeader = true
+		}
+		name := "unknown step"
+		if i < len(names) {
+			name = names[i]
+		}
+		fmt.Fprintf(w, "%s:\n\n", name)
+		if hasTests {
+			gotest.Write(w, other)
+			fmt.Fprintln(w)
+		} else {
+			gotest.WriteSummary(w, text)
+		}
+	}
+}
+
+func writeQuickfix(filename string, remote *git.RemoteURL, texts []string) error {
+	m, err := quickfix.NewPathMapper(remote)
+	if err != nil {
+		return err
+	}
+	var locs []quickfix.Location
+	for _, text := range texts {
+		locs = append(locs, quickfix.Find(text)...)
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := quickfix.Write(f, locs, m); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d error locations to %s\n", len(locs), filename)
+	return nil
+}
+
 // Wait waits for a build on the local branch to finish in CircleCI. If
 // rebaseAgainst is not empty, Wait will periodically fetch that branch from the
 // remote and rebase against it if it changes.
 func Wait(ctx context.Context, branch, remoteStr string, rebaseAgainst string) error {
+	return WaitWithOptions(ctx, branch, remoteStr, rebaseAgainst, nil)
+}
+
+// WaitWithOptions is like Wait, but lets you change how the build is
+// reported.
+func WaitWithOptions(ctx context.Context, branch, remoteStr string, rebaseAgainst string, opts *Options) error {
+	if opts == nil {
+		opts = new(Options)
+	}
 	for {
-		err := wait(ctx, branch, remoteStr, rebaseAgainst)
+		err := wait(ctx, branch, remoteStr, rebaseAgainst, opts)
 		if err == errChangedRemote {
 			select {
 			case <-ctx.Done():
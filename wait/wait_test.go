@@ -1,8 +1,10 @@
 package wait
 
 import (
+	"bytes"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -55,3 +57,22 @@ func TestShorterString(t *testing.T) {
 		t.Errorf("expected half hour cost to be %d, was %d", expectedMinTipLength, minTipLength)
 	}
 }
+
+func TestWriteOtherFailures(t *testing.T) {
+	testOutput := "=== RUN   TestAdd\n--- FAIL: TestAdd (0.00s)\n    add_test.go:9: wrong sum\nFAIL\nFAIL\texample.com/add\t0.01s\n"
+	lintOutput := "main.go:12:2: exported function Foo should have comment\n"
+	var buf bytes.Buffer
+	writeOtherFailures(&buf, []string{"make test", "make lint"}, []string{testOutput, lintOutput})
+	out := buf.String()
+	if strings.Contains(out, "TestAdd") {
+		t.Errorf("expected failing tests to be left out, got:\n%s", out)
+	}
+	if !strings.Contains(out, "make lint:") || !strings.Contains(out, "should have comment") {
+		t.Errorf("expected lint output, got:\n%s", out)
+	}
+	buf.Reset()
+	writeOtherFailures(&buf, []string{"make test"}, []string{testOutput})
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for a step with only failing tests, got:\n%s", buf.String())
+	}
+}